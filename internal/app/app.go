@@ -10,6 +10,7 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -27,13 +28,16 @@ import (
 	"golang.org/x/crypto/argon2"
 
 	"github.com/feherkaroly/vc/internal/config"
+	"github.com/feherkaroly/vc/internal/credstore"
 	"github.com/feherkaroly/vc/internal/dialog"
+	"github.com/feherkaroly/vc/internal/editor"
 	"github.com/feherkaroly/vc/internal/fileops"
 	"github.com/feherkaroly/vc/internal/fnbar"
 	"github.com/feherkaroly/vc/internal/menu"
 	"github.com/feherkaroly/vc/internal/model"
 	"github.com/feherkaroly/vc/internal/panel"
 	"github.com/feherkaroly/vc/internal/platform"
+	"github.com/feherkaroly/vc/internal/textenc"
 	"github.com/feherkaroly/vc/internal/theme"
 	"github.com/feherkaroly/vc/internal/vfs"
 	"github.com/feherkaroly/vc/internal/viewer"
@@ -50,6 +54,7 @@ type App struct {
 	MenuBar *menu.MenuBar
 	FnBar   *fnbar.FnBar
 	ConnMgr *vfs.ConnMgr
+	Secrets credstore.Store
 
 	activePanel    int // 0 = left, 1 = right
 	MenuActive     bool
@@ -62,17 +67,40 @@ type App struct {
 	activeDropdown   *menu.Dropdown
 	searchTimer      *time.Timer
 	CopyPreserveMode bool
+	DirectTransfer   bool
+	RateLimit        int  // global transfer limit in KB/s, 0 = unlimited
+	HexWidth         int  // bytes per line in the viewer's hex mode, 0 = default
+	SyntaxOff        bool // viewer syntax highlighting switched off for this session
+	ExternalEditor   bool // F4 runs $EDITOR instead of the internal editor
+
+	QuickView       bool   // the inactive panel previews the current entry (Ctrl+Q)
+	quickViewKey    string // identifies the preview shown, to skip rebuilding it
+	quickViewCancel func() // stops building the preview
+
+	saveOffered map[string]bool       // ad-hoc servers already offered for saving, by URL
+	startup     [2]config.PanelConfig // servers to connect the left/right panel to on start
+	idle        []func()              // run once no dialog is open
 }
 
 var Version string
 
-// New creates and initializes the application.
-func New(leftPath, rightPath string) *App {
+// New creates and initializes the application. left and right give the
+// panels' local directory in Path, or a saved server name or URL in Server
+// with the remote Path; the servers are connected once the UI is running.
+func New(left, right config.PanelConfig) *App {
 	a := &App{
 		TviewApp: tview.NewApplication(),
 		ConnMgr:  vfs.NewConnMgr(),
+		startup:  [2]config.PanelConfig{left, right},
 	}
 
+	leftPath, rightPath := left.Path, right.Path
+	if left.Server != "" {
+		leftPath = "."
+	}
+	if right.Server != "" {
+		rightPath = "."
+	}
 	a.LeftPanel = panel.NewPanel(leftPath, vfs.NewLocalFS())
 	a.RightPanel = panel.NewPanel(rightPath, vfs.NewLocalFS())
 	a.MenuBar = menu.NewMenuBar()
@@ -85,6 +113,12 @@ func New(leftPath, rightPath string) *App {
 	a.RightPanel.Mode = panel.DisplayMode(cfg.RightPanel.Mode)
 	a.RightPanel.SortMode = panel.SortMode(cfg.RightPanel.SortMode)
 	a.CopyPreserveMode = cfg.CopyPreserveMode
+	a.DirectTransfer = cfg.DirectTransfer
+	a.RateLimit = cfg.RateLimit
+	a.HexWidth = cfg.HexWidth
+	a.ExternalEditor = cfg.Editor == "external" || cfg.Editor == "" && os.Getenv("EDITOR") != ""
+	a.ConnMgr.Limit.SetRate(int64(cfg.RateLimit) * 1024)
+	a.Secrets = credstore.Open(cfg.CredentialStore)
 	a.LeftPanel.Refresh()
 	a.RightPanel.Refresh()
 
@@ -102,6 +136,23 @@ func New(leftPath, rightPath string) *App {
 
 	a.TviewApp.EnableMouse(true)
 
+	// Reflect dropped/restored connections in the panel titles; once a session
+	// is back, reload the panels so they show the same remote path again.
+	a.ConnMgr.OnStateChange = func(name string, state vfs.ConnState) {
+		go a.TviewApp.QueueUpdateDraw(func() {
+			for _, p := range []*panel.Panel{a.LeftPanel, a.RightPanel} {
+				if p.ConnectedServer != name {
+					continue
+				}
+				if state == vfs.StateConnected {
+					p.Refresh()
+				} else {
+					p.UpdateTitle()
+				}
+			}
+		})
+	}
+
 	a.MenuBar.OnClick = func(idx int) {
 		if a.ModalOpen {
 			return
@@ -179,10 +230,56 @@ func (a *App) Run() error {
 	go a.TviewApp.QueueUpdateDraw(func() {
 		a.LeftPanel.Render()
 		a.RightPanel.Render()
+		a.migratePasswords()
+		a.connectStartup()
 	})
 	return a.TviewApp.Run()
 }
 
+// connectStartup connects the panels to the servers given on the command
+// line or saved with the session. They connect one after the other since
+// each may prompt; the right panel's turn comes however the left's ends.
+func (a *App) connectStartup() {
+	a.resumePanel(a.LeftPanel, a.startup[0])
+	a.whenIdle(func() {
+		a.resumePanel(a.RightPanel, a.startup[1])
+	})
+}
+
+// resumePanel connects p to the server in pc and opens pc's path on it. A
+// saved server is connected by its name, anything else by URL.
+func (a *App) resumePanel(p *panel.Panel, pc config.PanelConfig) {
+	if pc.Server == "" {
+		return
+	}
+	if !config.IsServerURL(pc.Server) {
+		srv, ok := config.Load().Server(pc.Server)
+		if !ok {
+			return
+		}
+		remotePath := pc.Path
+		if remotePath == "" {
+			remotePath = "/"
+		}
+		a.withPassword(srv, func(srv config.ServerConfig) {
+			a.openSession(srv, func(session *vfs.Session) {
+				a.attachSession(p, session, remotePath)
+			})
+		})
+		return
+	}
+
+	rawURL := pc.Server
+	if pc.Path != "" {
+		if srv, _, err := config.ParseServerURL(rawURL); err == nil {
+			rawURL = config.ServerURL(srv, pc.Path)
+		}
+	}
+	a.openURL(rawURL, false, func(session *vfs.Session, remotePath string) {
+		a.attachSession(p, session, remotePath)
+	})
+}
+
 // GetActivePanel returns the currently focused panel.
 func (a *App) GetActivePanel() *panel.Panel {
 	if a.activePanel == 0 {
@@ -222,6 +319,25 @@ func (a *App) closeDialog(name string) {
 	a.activePanel = saved
 	a.focusActiveTable()
 	a.updatePanelStates()
+	if len(a.idle) > 0 {
+		// Another dialog may follow this one; look once the event is handled.
+		go a.TviewApp.QueueUpdateDraw(a.runIdle)
+	}
+}
+
+// whenIdle runs fn once no dialog is open, so the prompts started before it
+// are dealt with first, whichever way they end.
+func (a *App) whenIdle(fn func()) {
+	a.idle = append(a.idle, fn)
+	a.runIdle()
+}
+
+func (a *App) runIdle() {
+	for len(a.idle) > 0 && !a.ModalOpen {
+		fn := a.idle[0]
+		a.idle = a.idle[1:]
+		fn()
+	}
 }
 
 // ViewFile opens the F3 file viewer. For .zip files it shows the archive contents.
@@ -243,31 +359,27 @@ func (a *App) ViewFile() {
 		return
 	}
 
-	if p.IsRemote() {
-		// Remote file: read via VFS and display as text
-		data, err := p.FS.ReadFile(path)
-		if err != nil {
-			dialog.ShowError(a.Pages, "Read error: "+err.Error(), func() {
-				a.closeDialog("error")
-			})
-			a.ModalOpen = true
-			a.TviewApp.SetFocus(a.Pages)
-			return
-		}
-		v := viewer.NewFromText(path, string(data))
-		v.SetDoneFunc(func() {
-			a.closeDialog("viewer")
-		})
-		a.showDialog("viewer", v)
-		return
-	}
-
-	v := viewer.New(path)
-
-	v.SetDoneFunc(func() {
+	v := viewer.New(p.FS, path)
+	v.SetHexWidth(a.HexWidth)
+	v.SetHighlight(!a.SyntaxOff)
+	v.SetQueueUpdateFunc(func(f func()) {
+		a.TviewApp.QueueUpdateDraw(f)
+	})
+	closeViewer := func() {
+		v.Close()
+		a.TviewApp.SetAfterDrawFunc(nil)
+		a.HexWidth = v.HexWidth()
+		a.SyntaxOff = !v.Highlight()
 		a.closeDialog("viewer")
+	}
+	v.SetDoneFunc(closeViewer)
+	v.SetConvertFunc(func(enc *textenc.Encoding) {
+		closeViewer()
+		a.confirmConvert(p, path, enc)
 	})
-
+	// Images shown with a terminal graphics protocol are written to the
+	// terminal after each draw.
+	a.TviewApp.SetAfterDrawFunc(v.DrawGraphics)
 	a.showDialog("viewer", v)
 }
 
@@ -674,15 +786,19 @@ func addDirToTar(tw *tar.Writer, dirPath, prefix string) error {
 // OpenFile opens the current file with the system default application.
 func (a *App) OpenFile() {
 	p := a.GetActivePanel()
-	if p.IsRemote() {
-		a.showRemoteError("Open")
-		return
-	}
 	e := p.CurrentEntry()
 	if e == nil || e.IsDir {
 		return
 	}
-	path := filepath.Join(p.Path, e.Name)
+	if p.IsRemote() {
+		a.openRemoteFile(p, e.Name)
+		return
+	}
+	openWithSystem(filepath.Join(p.Path, e.Name))
+}
+
+// openWithSystem opens path with the desktop's default application.
+func openWithSystem(path string) {
 	switch runtime.GOOS {
 	case "darwin":
 		exec.Command("open", path).Start()
@@ -693,25 +809,67 @@ func (a *App) OpenFile() {
 	}
 }
 
-// EditFile opens the file in $EDITOR using Suspend.
+// EditFile opens the file in $EDITOR using Suspend, or in the internal
+// editor when $EDITOR is not set or the external one is turned off in the
+// Options menu.
 func (a *App) EditFile() {
 	p := a.GetActivePanel()
+	e := p.CurrentEntry()
+	if e == nil || e.IsDir {
+		return
+	}
+	if !a.ExternalEditor {
+		a.openEditor(p, p.FS.Join(p.Path, e.Name))
+		return
+	}
 	if p.IsRemote() {
-		a.showRemoteError("Edit")
+		a.editRemoteFile(p, e.Name)
 		return
 	}
-	e := p.CurrentEntry()
-	if e == nil || e.IsDir {
+
+	a.runEditor(filepath.Join(p.Path, e.Name))
+	p.Refresh()
+}
+
+// openEditor opens path in the internal editor. Remote files are loaded in
+// the background and saved straight back to the server.
+func (a *App) openEditor(p *panel.Panel, path string) {
+	show := func(ed *editor.Editor, err error) {
+		if err != nil {
+			a.showEditError("Edit error: " + err.Error())
+			return
+		}
+		ed.SetQueueUpdateFunc(func(f func()) {
+			a.TviewApp.QueueUpdateDraw(f)
+		})
+		ed.SetDoneFunc(func() {
+			a.closeDialog("editor")
+			p.Refresh()
+		})
+		a.showDialog("editor", ed)
+	}
+	if !p.IsRemote() {
+		show(editor.Open(p.FS, path))
 		return
 	}
 
+	spinner := a.showSpinner("Loading " + p.FS.Base(path))
+	go func() {
+		ed, err := editor.Open(p.FS, path)
+		a.removeSpinner(spinner)
+		a.TviewApp.QueueUpdateDraw(func() {
+			show(ed, err)
+		})
+	}()
+}
+
+// runEditor suspends the UI and opens path in $EDITOR (vi by default).
+func (a *App) runEditor(path string) {
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
 		editor = "vi"
 	}
 
-	path := filepath.Join(p.Path, e.Name)
-
 	a.TviewApp.Suspend(func() {
 		cmd := exec.Command(editor, path)
 		cmd.Stdin = os.Stdin
@@ -719,8 +877,6 @@ func (a *App) EditFile() {
 		cmd.Stderr = os.Stderr
 		cmd.Run()
 	})
-
-	p.Refresh()
 }
 
 // CopyFiles handles F5.
@@ -733,7 +889,6 @@ func (a *App) CopyFiles() {
 	}
 
 	desc := entryNames(entries)
-	srcFS := src.FS
 	dstFS := dst.FS
 
 	dialog.ShowInput(a.Pages, "Copy", "Copy "+desc+" to:", dst.Path, func(target string) {
@@ -742,20 +897,19 @@ func (a *App) CopyFiles() {
 			return
 		}
 
+		if config.IsServerURL(target) {
+			a.openURL(target, true, func(session *vfs.Session, remotePath string) {
+				a.copyEntries(src, entries, session.FS(), remotePath, session.Release)
+			})
+			return
+		}
+
 		// Relative path → resolve from destination panel
 		if !filepath.IsAbs(target) && dstFS.IsLocal() {
 			target = filepath.Join(src.Path, target)
 		}
 
-		a.runWithSpinnerOverwrite("Copying", dstFS, entries,
-			func(entry model.FileEntry) string {
-				return dstFS.Join(target, entry.Name)
-			},
-			func(entry model.FileEntry) error {
-				srcPath := srcFS.Join(src.Path, entry.Name)
-				dstPath := dstFS.Join(target, entry.Name)
-				return fileops.Copy(context.Background(), srcFS, srcPath, dstFS, dstPath, a.CopyPreserveMode, nil)
-			})
+		a.copyEntries(src, entries, dstFS, target, nil)
 	}, func() {
 		a.closeDialog("input")
 	})
@@ -763,6 +917,24 @@ func (a *App) CopyFiles() {
 	a.TviewApp.SetFocus(a.Pages)
 }
 
+// copyEntries copies entries from src into the target directory on dstFS.
+// onDone, if set, runs once the copy has finished.
+func (a *App) copyEntries(src *panel.Panel, entries []model.FileEntry, dstFS vfs.FileSystem, target string, onDone func()) {
+	srcFS := src.FS
+	a.runTransfer("Copying", dstFS, entries,
+		func(entry model.FileEntry) string {
+			return dstFS.Join(target, entry.Name)
+		},
+		func(ctx context.Context, entry model.FileEntry, onProgress func(fileops.Progress)) error {
+			srcPath := srcFS.Join(src.Path, entry.Name)
+			dstPath := dstFS.Join(target, entry.Name)
+			if a.DirectTransfer {
+				return fileops.CopyDirect(ctx, srcFS, srcPath, dstFS, dstPath, a.CopyPreserveMode, onProgress)
+			}
+			return fileops.Copy(ctx, srcFS, srcPath, dstFS, dstPath, a.CopyPreserveMode, onProgress)
+		}, onDone)
+}
+
 // MoveFiles handles F6.
 func (a *App) MoveFiles() {
 	src := a.GetActivePanel()
@@ -773,7 +945,6 @@ func (a *App) MoveFiles() {
 	}
 
 	desc := entryNames(entries)
-	srcFS := src.FS
 	dstFS := dst.FS
 
 	defaultTarget := dst.Path
@@ -787,27 +958,19 @@ func (a *App) MoveFiles() {
 			return
 		}
 
+		if config.IsServerURL(target) {
+			a.openURL(target, true, func(session *vfs.Session, remotePath string) {
+				a.moveEntries(src, entries, session.FS(), remotePath, session.Release)
+			})
+			return
+		}
+
 		// Relative path → resolve from source panel directory
 		if !filepath.IsAbs(target) && dstFS.IsLocal() {
 			target = filepath.Join(src.Path, target)
 		}
 
-		singleEntry := len(entries) == 1
-		a.runWithSpinnerOverwrite("Moving", dstFS, entries,
-			func(entry model.FileEntry) string {
-				if singleEntry {
-					return target
-				}
-				return dstFS.Join(target, entry.Name)
-			},
-			func(entry model.FileEntry) error {
-				srcPath := srcFS.Join(src.Path, entry.Name)
-				dstPath := target
-				if !singleEntry {
-					dstPath = dstFS.Join(target, entry.Name)
-				}
-				return fileops.Move(context.Background(), srcFS, srcPath, dstFS, dstPath, nil)
-			})
+		a.moveEntries(src, entries, dstFS, target, nil)
 	}, func() {
 		a.closeDialog("input")
 	})
@@ -815,6 +978,32 @@ func (a *App) MoveFiles() {
 	a.TviewApp.SetFocus(a.Pages)
 }
 
+// moveEntries moves entries from src to target on dstFS. A single entry is
+// moved to target itself, several into the target directory. onDone, if
+// set, runs once the move has finished.
+func (a *App) moveEntries(src *panel.Panel, entries []model.FileEntry, dstFS vfs.FileSystem, target string, onDone func()) {
+	srcFS := src.FS
+	singleEntry := len(entries) == 1
+	a.runTransfer("Moving", dstFS, entries,
+		func(entry model.FileEntry) string {
+			if singleEntry {
+				return target
+			}
+			return dstFS.Join(target, entry.Name)
+		},
+		func(ctx context.Context, entry model.FileEntry, onProgress func(fileops.Progress)) error {
+			srcPath := srcFS.Join(src.Path, entry.Name)
+			dstPath := target
+			if !singleEntry {
+				dstPath = dstFS.Join(target, entry.Name)
+			}
+			if a.DirectTransfer {
+				return fileops.MoveDirect(ctx, srcFS, srcPath, dstFS, dstPath, onProgress)
+			}
+			return fileops.Move(ctx, srcFS, srcPath, dstFS, dstPath, onProgress)
+		}, onDone)
+}
+
 // RenameFile handles Shift+F6 — simple in-place rename.
 func (a *App) RenameFile() {
 	p := a.GetActivePanel()
@@ -1185,8 +1374,9 @@ func (a *App) DeactivateMenu() {
 func (a *App) showMenuDropdown() {
 	panelDefs := func(p *panel.Panel) *menu.MenuDefs {
 		return &menu.MenuDefs{
-			OnBriefMode: func() { p.Mode = panel.ModeBrief; a.SaveConfig(); a.DeactivateMenu() },
-			OnFullMode:  func() { p.Mode = panel.ModeFull; a.SaveConfig(); a.DeactivateMenu() },
+			OnBriefMode: func() { a.setPanelMode(p, panel.ModeBrief) },
+			OnFullMode:  func() { a.setPanelMode(p, panel.ModeFull) },
+			OnQuickView: func() { a.DeactivateMenu(); a.quickViewIn(p) },
 			OnSortName:  func() { a.setSortModeOn(p, panel.SortByName); a.DeactivateMenu() },
 			OnSortExt:   func() { a.setSortModeOn(p, panel.SortByExtension); a.DeactivateMenu() },
 			OnSortSize:  func() { a.setSortModeOn(p, panel.SortBySize); a.DeactivateMenu() },
@@ -1203,12 +1393,15 @@ func (a *App) showMenuDropdown() {
 			OnExportConfig: func() { a.DeactivateMenu(); a.ExportConfig() },
 			OnImportConfig: func() { a.DeactivateMenu(); a.ImportConfig() },
 			OnQuickPaths:   func() { a.DeactivateMenu(); a.ShowQuickPathsDialog() },
+			OnGoTo:         func() { a.DeactivateMenu(); a.GoTo() },
 			OnCheckUpdate:  func() { a.DeactivateMenu(); a.CheckForUpdates() },
+			OnRunRemote:    func() { a.DeactivateMenu(); a.RunRemoteCommand() },
 			OnSymlink:      func() { a.DeactivateMenu(); a.CreateSymlink() },
 			OnRename:       func() { a.DeactivateMenu(); a.RenameFile() },
 			OnChmod:        func() { a.DeactivateMenu(); a.ShowChmodDialog() },
 			OnConnect:      func() { a.DeactivateMenu(); a.ShowServerDialogForPanel(p) },
 			OnDisconnect:   func() { a.DeactivateMenu(); a.disconnectPanel(p) },
+			OnConvertEncoding: func() { a.DeactivateMenu(); a.ConvertEncoding() },
 		}
 	}
 
@@ -1228,6 +1421,24 @@ func (a *App) showMenuDropdown() {
 			a.SaveConfig()
 			a.DeactivateMenu()
 		}
+		defs.DirectTransferOn = a.DirectTransfer
+		defs.OnToggleDirect = func() {
+			a.DirectTransfer = !a.DirectTransfer
+			a.SaveConfig()
+			a.DeactivateMenu()
+		}
+		defs.OnRateLimit = func() { a.DeactivateMenu(); a.EditRateLimit() }
+		defs.ExternalEditorOn = a.ExternalEditor
+		defs.OnToggleEditor = func() {
+			a.ExternalEditor = !a.ExternalEditor
+			cfg := config.Load()
+			cfg.Editor = "internal"
+			if a.ExternalEditor {
+				cfg.Editor = "external"
+			}
+			a.saveConfigWithServers(cfg)
+			a.DeactivateMenu()
+		}
 		items = menu.OptionsMenuItems(defs)
 	case 4:
 		items = menu.RightMenuItems(panelDefs(a.RightPanel))
@@ -1346,6 +1557,9 @@ func (a *App) ExportConfig() {
 		}
 
 		cfg := config.Load()
+		for i := range cfg.Servers {
+			cfg.Servers[i].Password = "" // secrets stay in the credential store
+		}
 		data, err := json.MarshalIndent(cfg, "", "  ")
 		if err != nil {
 			dialog.ShowError(a.Pages, "Export error: "+err.Error(), func() {
@@ -1405,12 +1619,12 @@ func (a *App) ImportConfig() {
 		// Apply panel settings
 		a.LeftPanel.Mode = panel.DisplayMode(imported.LeftPanel.Mode)
 		a.LeftPanel.SortMode = panel.SortMode(imported.LeftPanel.SortMode)
-		if imported.LeftPanel.Path != "" {
+		if imported.LeftPanel.Path != "" && imported.LeftPanel.Server == "" {
 			a.LeftPanel.NavigateTo(imported.LeftPanel.Path, "")
 		}
 		a.RightPanel.Mode = panel.DisplayMode(imported.RightPanel.Mode)
 		a.RightPanel.SortMode = panel.SortMode(imported.RightPanel.SortMode)
-		if imported.RightPanel.Path != "" {
+		if imported.RightPanel.Path != "" && imported.RightPanel.Server == "" {
 			a.RightPanel.NavigateTo(imported.RightPanel.Path, "")
 		}
 
@@ -1428,10 +1642,10 @@ func (a *App) ImportConfig() {
 			}
 		}
 
-		a.saveConfigWithServers(&config.Config{Servers: merged})
+		current.Servers = merged
+		a.saveWithPasswords(current)
 		a.LeftPanel.Refresh()
 		a.RightPanel.Refresh()
-	
 	}, func() {
 		a.closeDialog("input")
 	})
@@ -1443,6 +1657,7 @@ func (a *App) swapPanels() {
 	a.LeftPanel.Path, a.RightPanel.Path = a.RightPanel.Path, a.LeftPanel.Path
 	a.LeftPanel.FS, a.RightPanel.FS = a.RightPanel.FS, a.LeftPanel.FS
 	a.LeftPanel.ConnectedServer, a.RightPanel.ConnectedServer = a.RightPanel.ConnectedServer, a.LeftPanel.ConnectedServer
+	a.LeftPanel.Session, a.RightPanel.Session = a.RightPanel.Session, a.LeftPanel.Session
 	a.LeftPanel.Refresh()
 	a.RightPanel.Refresh()
 }
@@ -1477,9 +1692,11 @@ func (a *App) ShowServerDialogForPanel(targetPanel *panel.Panel) {
 				a.Pages.RemovePage("server_dialog")
 				dialog.ShowServerEdit(a.Pages, "Add Server", config.ServerConfig{Protocol: "sftp"}, func(srv config.ServerConfig) {
 					a.Pages.RemovePage("server_edit")
-					cfg.Servers = append(cfg.Servers, srv)
-					a.saveConfigWithServers(cfg)
-					showDialog()
+					a.storeServerPassword(config.ServerConfig{}, &srv, func() {
+						cfg.Servers = append(cfg.Servers, srv)
+						a.saveConfigWithServers(cfg)
+						showDialog()
+					})
 				}, func() {
 					a.Pages.RemovePage("server_edit")
 					a.ModalOpen = false
@@ -1505,9 +1722,11 @@ func (a *App) ShowServerDialogForPanel(targetPanel *panel.Panel) {
 				a.Pages.RemovePage("server_dialog")
 				dialog.ShowServerEdit(a.Pages, "Edit Server", srv, func(updated config.ServerConfig) {
 					a.Pages.RemovePage("server_edit")
-					cfg.Servers[idx] = updated
-					a.saveConfigWithServers(cfg)
-					showDialog()
+					a.storeServerPassword(srv, &updated, func() {
+						cfg.Servers[idx] = updated
+						a.saveConfigWithServers(cfg)
+						showDialog()
+					})
 				}, func() {
 					a.Pages.RemovePage("server_edit")
 					a.ModalOpen = false
@@ -1528,6 +1747,7 @@ func (a *App) ShowServerDialogForPanel(targetPanel *panel.Panel) {
 				dialog.ShowConfirm(a.Pages, "Delete", confirmMsg, func(yes bool) {
 					a.closeDialog("confirm")
 					if yes {
+						a.forgetServerPassword(cfg.Servers[idx])
 						cfg.Servers = append(cfg.Servers[:idx], cfg.Servers[idx+1:]...)
 						a.saveConfigWithServers(cfg)
 					}
@@ -1574,9 +1794,11 @@ func (a *App) ShowServerDialog() {
 				a.Pages.RemovePage("server_dialog")
 				dialog.ShowServerEdit(a.Pages, "Add Server", config.ServerConfig{Protocol: "sftp"}, func(srv config.ServerConfig) {
 					a.Pages.RemovePage("server_edit")
-					cfg.Servers = append(cfg.Servers, srv)
-					a.saveConfigWithServers(cfg)
-					showDialog()
+					a.storeServerPassword(config.ServerConfig{}, &srv, func() {
+						cfg.Servers = append(cfg.Servers, srv)
+						a.saveConfigWithServers(cfg)
+						showDialog()
+					})
 				}, func() {
 					a.Pages.RemovePage("server_edit")
 					a.ModalOpen = false
@@ -1602,9 +1824,11 @@ func (a *App) ShowServerDialog() {
 				a.Pages.RemovePage("server_dialog")
 				dialog.ShowServerEdit(a.Pages, "Edit Server", srv, func(updated config.ServerConfig) {
 					a.Pages.RemovePage("server_edit")
-					cfg.Servers[idx] = updated
-					a.saveConfigWithServers(cfg)
-					showDialog()
+					a.storeServerPassword(srv, &updated, func() {
+						cfg.Servers[idx] = updated
+						a.saveConfigWithServers(cfg)
+						showDialog()
+					})
 				}, func() {
 					a.Pages.RemovePage("server_edit")
 					a.ModalOpen = false
@@ -1625,6 +1849,7 @@ func (a *App) ShowServerDialog() {
 				dialog.ShowConfirm(a.Pages, "Delete", confirmMsg, func(yes bool) {
 					a.closeDialog("confirm")
 					if yes {
+						a.forgetServerPassword(cfg.Servers[idx])
 						cfg.Servers = append(cfg.Servers[:idx], cfg.Servers[idx+1:]...)
 						a.saveConfigWithServers(cfg)
 					}
@@ -1650,6 +1875,38 @@ func (a *App) ShowServerDialog() {
 }
 
 func (a *App) connectPanel(p *panel.Panel, srv config.ServerConfig) {
+	a.withPassword(srv, func(srv config.ServerConfig) {
+		a.dialPanel(p, srv)
+	})
+}
+
+// confirmServerCert asks whether to trust a certificate that failed
+// verification and, if so, pins its fingerprint and calls retry.
+func (a *App) confirmServerCert(srv config.ServerConfig, certErr *vfs.CertError, retry func(config.ServerConfig)) {
+	msg := fmt.Sprintf("The certificate of %s could not be verified:\n%v\n\nSHA-256 %s\n\nTrust this certificate?",
+		certErr.Host, certErr.Err, certErr.Fingerprint)
+	dialog.ShowConfirm(a.Pages, "Untrusted certificate", msg, func(yes bool) {
+		a.closeDialog("confirm")
+		if !yes {
+			return
+		}
+		srv.TLSFingerprint = certErr.Fingerprint
+		cfg := config.Load()
+		for i := range cfg.Servers {
+			if cfg.Servers[i].Name == srv.Name {
+				cfg.Servers[i].TLSFingerprint = certErr.Fingerprint
+			}
+		}
+		a.saveConfigWithServers(cfg)
+		retry(srv)
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
+
+// openSession connects to srv in the background and calls fn on the UI
+// goroutine with the new session. Failures are reported to the user.
+func (a *App) openSession(srv config.ServerConfig, fn func(*vfs.Session)) {
 	// Show a simple "connecting" message
 	dialog.ShowError(a.Pages, "Connecting to "+srv.Name+"...", nil)
 	a.ModalOpen = true
@@ -1657,9 +1914,16 @@ func (a *App) connectPanel(p *panel.Panel, srv config.ServerConfig) {
 	a.TviewApp.ForceDraw()
 
 	go func() {
-		fs, err := a.ConnMgr.Connect(srv)
+		session, err := a.ConnMgr.Open(srv)
 		a.TviewApp.QueueUpdateDraw(func() {
 			a.closeDialog("error")
+			var certErr *vfs.CertError
+			if errors.As(err, &certErr) {
+				a.confirmServerCert(srv, certErr, func(srv config.ServerConfig) {
+					a.openSession(srv, fn)
+				})
+				return
+			}
 			if err != nil {
 				dialog.ShowError(a.Pages, "Connection failed: "+err.Error(), func() {
 					a.closeDialog("error")
@@ -1668,26 +1932,42 @@ func (a *App) connectPanel(p *panel.Panel, srv config.ServerConfig) {
 				a.TviewApp.SetFocus(a.Pages)
 				return
 			}
-
-			p.FS = fs
-			p.ConnectedServer = srv.Name
-			p.Path = "/"
-			p.Refresh()
-		
+			fn(session)
 		})
 	}()
 }
 
+func (a *App) dialPanel(p *panel.Panel, srv config.ServerConfig) {
+	a.openSession(srv, func(session *vfs.Session) {
+		a.attachSession(p, session, "/")
+	})
+}
+
+// attachSession switches p to the remote filesystem of session, releasing
+// the session it held before.
+func (a *App) attachSession(p *panel.Panel, session *vfs.Session, path string) {
+	if p.Session != nil {
+		p.Session.Release()
+	}
+	p.FS = session.FS()
+	p.Session = session
+	p.ConnectedServer = session.Name()
+	p.Path = "/"
+	p.Refresh()
+	if path != "/" {
+		p.NavigateTo(path, "")
+	}
+}
+
 func (a *App) disconnectPanel(p *panel.Panel) {
-	name := p.ConnectedServer
-	if name == "" {
+	if p.Session == nil {
 		return
 	}
 
-	// Check if the other panel uses the same connection
-	other := a.GetInactivePanel()
-	otherUsesSame := other.ConnectedServer == name
-
+	// The connection itself stays open while the other panel still has a
+	// session on it.
+	p.Session.Release()
+	p.Session = nil
 	p.FS = vfs.NewLocalFS()
 	p.ConnectedServer = ""
 	home, err := os.UserHomeDir()
@@ -1696,29 +1976,40 @@ func (a *App) disconnectPanel(p *panel.Panel) {
 	}
 	p.Path = home
 	p.Refresh()
-
-
-	if !otherUsesSame {
-		a.ConnMgr.Disconnect(name)
-	}
 }
 
 func (a *App) saveConfigWithServers(cfg *config.Config) {
-	leftPath := ""
-	if a.LeftPanel.FS.IsLocal() {
-		leftPath = a.LeftPanel.Path
-	}
-	rightPath := ""
-	if a.RightPanel.FS.IsLocal() {
-		rightPath = a.RightPanel.Path
-	}
-	cfg.LeftPanel = config.PanelConfig{Mode: int(a.LeftPanel.Mode), SortMode: int(a.LeftPanel.SortMode), Path: leftPath}
-	cfg.RightPanel = config.PanelConfig{Mode: int(a.RightPanel.Mode), SortMode: int(a.RightPanel.SortMode), Path: rightPath}
+	cfg.LeftPanel = panelConfig(a.LeftPanel, cfg)
+	cfg.RightPanel = panelConfig(a.RightPanel, cfg)
 	cfg.ActivePanel = a.activePanel
 	cfg.CopyPreserveMode = a.CopyPreserveMode
+	cfg.DirectTransfer = a.DirectTransfer
+	cfg.RateLimit = a.RateLimit
+	cfg.HexWidth = a.HexWidth
 	config.Save(cfg)
 }
 
+// panelConfig describes p for saving. A remote panel records its server by
+// name, or as a URL when it was opened ad hoc, so it can be reconnected.
+func panelConfig(p *panel.Panel, cfg *config.Config) config.PanelConfig {
+	pc := config.PanelConfig{Mode: int(p.ListMode()), SortMode: int(p.SortMode), Path: p.Path}
+	if p.Session == nil {
+		if !p.FS.IsLocal() {
+			pc.Path = ""
+		}
+		return pc
+	}
+	srv := p.Session.Config()
+	pc.Server = config.ServerURL(srv, "/")
+	for _, s := range cfg.Servers {
+		if s.Name == srv.Name {
+			pc.Server = srv.Name
+			break
+		}
+	}
+	return pc
+}
+
 // ShowDriveSelector shows a drive selection dialog (Windows only).
 func (a *App) ShowDriveSelector() {
 	drives := platform.GetDrives()
@@ -1749,7 +2040,11 @@ func (a *App) ShowQuickPathsDialog() {
 		dialog.ShowQuickPathsDialog(a.Pages, cfg.QuickPaths, dialog.QuickPathsCallbacks{
 			OnSet: func(slot int) {
 				key := fmt.Sprintf("%d", slot)
-				cfg.QuickPaths[key] = a.GetActivePanel().Path
+				p := a.GetActivePanel()
+				cfg.QuickPaths[key] = p.Path
+				if p.Session != nil {
+					cfg.QuickPaths[key] = config.ServerURL(p.Session.Config(), p.Path)
+				}
 				a.saveConfigWithServers(cfg)
 				a.Pages.RemovePage("quickpaths")
 				a.ModalOpen = false
@@ -1804,6 +2099,10 @@ func (a *App) navigateToQuickPath(slot int, paths map[string]string) {
 		return
 	}
 	p := a.GetActivePanel()
+	if config.IsServerURL(path) {
+		a.goToURL(p, path)
+		return
+	}
 	p.NavigateTo(path, "")
 }
 
@@ -1919,68 +2218,42 @@ func (a *App) runWithSpinner(displayName string, fn func() error) {
 	}()
 }
 
-// runWithSpinnerOverwrite is like runWithSpinner but checks for existing destination
-// files and shows an overwrite confirmation dialog before each operation.
-func (a *App) runWithSpinnerOverwrite(displayName string, dstFS vfs.FileSystem, entries []model.FileEntry, dstPathFn func(entry model.FileEntry) string, fn func(entry model.FileEntry) error) {
+// runTransfer runs a copy or move of entries behind a progress dialog. It
+// checks for existing destination files and asks before overwriting them.
+// Escape or Abort cancels, and +/- change the global rate limit while the
+// transfer runs. onDone, if not nil, is called from the worker goroutine
+// once it finishes.
+func (a *App) runTransfer(title string, dstFS vfs.FileSystem, entries []model.FileEntry, dstPathFn func(entry model.FileEntry) string, fn func(ctx context.Context, entry model.FileEntry, onProgress func(fileops.Progress)) error, onDone func()) {
 	p := a.GetActivePanel()
+	ctx, cancel := context.WithCancel(context.Background())
 
-	spinView := tview.NewTextView()
-	spinView.SetBackgroundColor(theme.ColorDialogBg)
-	spinView.SetTextColor(theme.ColorDialogFg)
-	spinView.SetBorder(true)
-	spinView.SetBorderColor(theme.ColorDialogBorder)
-	spinView.SetTextAlign(tview.AlignCenter)
-
-	if len(displayName) > 20 {
-		displayName = displayName[:20] + "..."
-	}
-	boxW := len(displayName) + 6
-	if boxW < 18 {
-		boxW = 18
-	}
-	_, _, screenW, screenH := a.Pages.GetInnerRect()
-	spinView.SetRect(screenW-boxW-1, screenH-4, boxW, 3)
-	spinView.SetText(displayName + " |")
-
-	a.Pages.AddPage("spinner", spinView, false, true)
-	a.focusActiveTable()
+	progress := dialog.NewProgressDialog(title, cancel)
+	progress.SetLimit(int64(a.RateLimit)*1024, func(faster bool) {
+		a.setRateLimit(stepRateLimit(a.RateLimit, faster))
+		progress.SetLimit(int64(a.RateLimit)*1024, nil)
+	})
+	a.showDialog("progress", progress)
 
 	go func() {
-		spinChars := [4]rune{'|', '/', '-', '\\'}
-		spinIdx := 0
-		done := make(chan struct{})
-
-		go func() {
-			ticker := time.NewTicker(150 * time.Millisecond)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-done:
-					return
-				case <-ticker.C:
-					spinIdx = (spinIdx + 1) % 4
-					ch := spinChars[spinIdx]
-					a.TviewApp.QueueUpdateDraw(func() {
-						spinView.SetText(fmt.Sprintf("%s %c", displayName, ch))
-					})
-				}
-			}
-		}()
+		defer cancel()
 
 		var firstErr error
 		overwriteAll := false
+		var lastUpdate time.Time
 
-		for _, entry := range entries {
+		for i, entry := range entries {
+			if ctx.Err() != nil {
+				break
+			}
 			if !overwriteAll {
 				dstPath := dstPathFn(entry)
 				if _, err := dstFS.Stat(dstPath); err == nil {
 					// Destination exists — ask user
 					ch := make(chan dialog.OverwriteChoice, 1)
 					a.TviewApp.QueueUpdateDraw(func() {
-						a.ModalOpen = true
 						dialog.ShowOverwrite(a.Pages, entry.Name, func(choice dialog.OverwriteChoice) {
 							a.Pages.RemovePage("overwrite")
-							a.ModalOpen = false
+							a.TviewApp.SetFocus(progress)
 							ch <- choice
 						})
 						a.TviewApp.SetFocus(a.Pages)
@@ -1998,22 +2271,32 @@ func (a *App) runWithSpinnerOverwrite(displayName string, dstFS vfs.FileSystem,
 				}
 			}
 
-			if err := fn(entry); err != nil {
+			index := i + 1
+			err := fn(ctx, entry, func(pr fileops.Progress) {
+				// Redraw at most ten times a second
+				if time.Since(lastUpdate) < 100*time.Millisecond && pr.Done < pr.Total {
+					return
+				}
+				lastUpdate = time.Now()
+				pr.FileIndex, pr.FileCount = index, len(entries)
+				a.TviewApp.QueueUpdateDraw(func() {
+					progress.Update(pr)
+				})
+			})
+			if err != nil {
 				firstErr = err
 				break
 			}
 		}
 
 	finish:
-		close(done)
+		if onDone != nil {
+			onDone()
+		}
 
 		a.TviewApp.QueueUpdateDraw(func() {
-			saved := a.activePanel
-			a.Pages.RemovePage("spinner")
-			a.activePanel = saved
-			a.focusActiveTable()
-			a.updatePanelStates()
-			if firstErr != nil {
+			a.closeDialog("progress")
+			if firstErr != nil && !errors.Is(firstErr, context.Canceled) {
 				dialog.ShowError(a.Pages, "Error: "+firstErr.Error(), func() {
 					a.closeDialog("error")
 				})
@@ -2028,6 +2311,71 @@ func (a *App) runWithSpinnerOverwrite(displayName string, dstFS vfs.FileSystem,
 	}()
 }
 
+// rateSteps are the limits, in KB/s, that +/- step through in the progress
+// dialog. Above the last step the transfer is unlimited.
+var rateSteps = []int{64, 128, 256, 512, 1024, 2048, 5120, 10240, 20480, 51200}
+
+// stepRateLimit returns the next lower or higher rate limit; 0 is unlimited.
+func stepRateLimit(current int, faster bool) int {
+	if faster {
+		if current == 0 {
+			return 0
+		}
+		for _, step := range rateSteps {
+			if step > current {
+				return step
+			}
+		}
+		return 0
+	}
+	if current == 0 {
+		return rateSteps[len(rateSteps)-1]
+	}
+	for i := len(rateSteps) - 1; i >= 0; i-- {
+		if rateSteps[i] < current {
+			return rateSteps[i]
+		}
+	}
+	return rateSteps[0]
+}
+
+// EditRateLimit asks for the global transfer rate limit.
+func (a *App) EditRateLimit() {
+	current := ""
+	if a.RateLimit > 0 {
+		current = strconv.Itoa(a.RateLimit)
+	}
+	dialog.ShowInput(a.Pages, "Transfer rate limit", "KB/s for all servers (empty = unlimited):", current, func(text string) {
+		a.closeDialog("input")
+		kbps := 0
+		if text = strings.TrimSpace(text); text != "" {
+			n, err := strconv.Atoi(text)
+			if err != nil || n < 0 {
+				dialog.ShowError(a.Pages, "Invalid rate limit: "+text, func() {
+					a.closeDialog("error")
+				})
+				a.ModalOpen = true
+				a.TviewApp.SetFocus(a.Pages)
+				return
+			}
+			kbps = n
+		}
+		a.setRateLimit(kbps)
+	}, func() {
+		a.closeDialog("input")
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
+
+// setRateLimit changes the global transfer limit (KB/s, 0 = unlimited),
+// applying it to running transfers and saving it.
+func (a *App) setRateLimit(kbps int) {
+	a.RateLimit = kbps
+	a.ConnMgr.Limit.SetRate(int64(kbps) * 1024)
+	a.SaveConfig()
+}
+
 // encryptFile encrypts srcPath with AES-256-GCM and writes to dstPath.
 // File format: [2 byte filename length][filename][16 byte salt][12 byte nonce][ciphertext+tag]
 func encryptFile(srcPath, dstPath, password string) error {
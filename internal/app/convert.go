@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/feherkaroly/vc/internal/dialog"
+	"github.com/feherkaroly/vc/internal/fileops"
+	"github.com/feherkaroly/vc/internal/panel"
+	"github.com/feherkaroly/vc/internal/textenc"
+)
+
+// ConvertEncoding asks for the encoding of the file under the cursor,
+// suggesting the detected one, and rewrites the file as UTF-8.
+func (a *App) ConvertEncoding() {
+	p := a.GetActivePanel()
+	e := p.CurrentEntry()
+	if e == nil || e.IsDir {
+		return
+	}
+	path := p.FS.Join(p.Path, e.Name)
+
+	guess := textenc.UTF8
+	if f, err := p.FS.Open(path); err == nil {
+		head := make([]byte, 8192)
+		n, _ := io.ReadFull(f, head)
+		f.Close()
+		guess = textenc.Detect(head[:n])
+	}
+
+	dialog.ShowInput(a.Pages, "Convert to UTF-8", "Convert "+e.Name+" from:", guess.Name, func(name string) {
+		a.closeDialog("input")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return
+		}
+		enc := textenc.ByName(name)
+		if enc == nil {
+			dialog.ShowError(a.Pages, "Unknown encoding "+name+". Known: "+strings.Join(textenc.Names(), ", "), func() {
+				a.closeDialog("error")
+			})
+			a.ModalOpen = true
+			a.TviewApp.SetFocus(a.Pages)
+			return
+		}
+		a.convertToUTF8(p, path, enc)
+	}, func() {
+		a.closeDialog("input")
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
+
+// confirmConvert asks before converting path, which the viewer showed
+// decoded with enc.
+func (a *App) confirmConvert(p *panel.Panel, path string, enc *textenc.Encoding) {
+	msg := "Convert " + p.FS.Base(path) + " from " + enc.Name + " to UTF-8?"
+	dialog.ShowConfirm(a.Pages, "Convert to UTF-8", msg, func(yes bool) {
+		a.closeDialog("confirm")
+		if yes {
+			a.convertToUTF8(p, path, enc)
+		}
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
+
+// convertToUTF8 rewrites path as UTF-8 in the background.
+func (a *App) convertToUTF8(p *panel.Panel, path string, enc *textenc.Encoding) {
+	if enc == textenc.UTF8 {
+		return
+	}
+	a.runWithSpinner(p.FS.Base(path), func() error {
+		return fileops.ConvertToUTF8(context.Background(), p.FS, path, enc)
+	})
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"errors"
+
+	"github.com/feherkaroly/vc/internal/config"
+	"github.com/feherkaroly/vc/internal/credstore"
+	"github.com/feherkaroly/vc/internal/dialog"
+)
+
+// withSecrets runs fn with the credential store, asking for the vault's
+// master password first if it is still locked.
+func (a *App) withSecrets(fn func(store credstore.Store)) {
+	a.withSecretsOr(fn, nil)
+}
+
+// withSecretsOr is like withSecrets, but calls locked, if set, when the
+// vault stays locked.
+func (a *App) withSecretsOr(fn func(store credstore.Store), locked func()) {
+	if !credstore.NeedsUnlock(a.Secrets) {
+		fn(a.Secrets)
+		return
+	}
+
+	vault := a.Secrets.(*credstore.Vault)
+	title := "Unlock credential vault"
+	if !vault.Exists() {
+		title = "New vault master password"
+	}
+	dialog.ShowPasswordDialog(a.Pages, title, !vault.Exists(), func(master string) {
+		a.closeDialog("password")
+		if err := vault.Unlock(master); err != nil {
+			dialog.ShowError(a.Pages, "Vault error: "+err.Error(), func() {
+				a.closeDialog("error")
+				if locked != nil {
+					locked()
+				}
+			})
+			a.ModalOpen = true
+			a.TviewApp.SetFocus(a.Pages)
+			return
+		}
+		fn(a.Secrets)
+	}, func() {
+		a.closeDialog("password")
+		if locked != nil {
+			locked()
+		}
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
+
+// withPassword fills in srv.Password from the credential store before calling fn.
+func (a *App) withPassword(srv config.ServerConfig, fn func(srv config.ServerConfig)) {
+	if !srv.StoredPassword || srv.Password != "" {
+		fn(srv)
+		return
+	}
+	a.withSecrets(func(store credstore.Store) {
+		pw, err := store.Get(srv.Name)
+		if err != nil && !errors.Is(err, credstore.ErrNotFound) {
+			dialog.ShowError(a.Pages, "Credential error: "+err.Error(), func() {
+				a.closeDialog("error")
+			})
+			a.ModalOpen = true
+			a.TviewApp.SetFocus(a.Pages)
+			return
+		}
+		srv.Password = pw
+		fn(srv)
+	})
+}
+
+// storeServerPassword moves a password typed into the server form into the
+// credential store so it never reaches config.json. A blank password keeps
+// the previously stored one, following the server if it was renamed.
+func (a *App) storeServerPassword(old config.ServerConfig, srv *config.ServerConfig, done func()) {
+	renamed := old.StoredPassword && old.Name != srv.Name
+	if srv.Password == "" && !renamed {
+		done()
+		return
+	}
+
+	a.withSecrets(func(store credstore.Store) {
+		secret := srv.Password
+		if secret == "" {
+			s, err := store.Get(old.Name)
+			if errors.Is(err, credstore.ErrNotFound) {
+				srv.StoredPassword = false
+				done()
+				return
+			}
+			if err != nil {
+				dialog.ShowError(a.Pages, "Credential error: "+err.Error(), func() {
+					a.closeDialog("error")
+				})
+				a.ModalOpen = true
+				a.TviewApp.SetFocus(a.Pages)
+				return
+			}
+			secret = s
+		}
+
+		if err := store.Set(srv.Name, secret); err != nil {
+			dialog.ShowError(a.Pages, "Credential error: "+err.Error(), func() {
+				a.closeDialog("error")
+			})
+			a.ModalOpen = true
+			a.TviewApp.SetFocus(a.Pages)
+			return
+		}
+		if renamed {
+			store.Delete(old.Name)
+		}
+		srv.Password = ""
+		srv.StoredPassword = true
+		done()
+	})
+}
+
+// forgetServerPassword removes a deleted server's password from the store.
+// A locked vault is left alone rather than prompting just to clean up.
+func (a *App) forgetServerPassword(srv config.ServerConfig) {
+	if !srv.StoredPassword || credstore.NeedsUnlock(a.Secrets) {
+		return
+	}
+	a.Secrets.Delete(srv.Name)
+}
+
+// migratePasswords moves plaintext passwords left in config.json by older
+// versions into the credential store.
+func (a *App) migratePasswords() {
+	if cfg := config.Load(); hasPasswords(cfg) {
+		a.saveWithPasswords(cfg)
+	}
+}
+
+// hasPasswords reports whether cfg holds any plaintext password.
+func hasPasswords(cfg *config.Config) bool {
+	for _, s := range cfg.Servers {
+		if s.Password != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// saveWithPasswords saves cfg after moving the plaintext passwords in it
+// into the credential store, so they never reach config.json. Nothing is
+// saved if the vault stays locked or a password can't be stored.
+func (a *App) saveWithPasswords(cfg *config.Config) {
+	if !hasPasswords(cfg) {
+		a.saveConfigWithServers(cfg)
+		return
+	}
+	a.withSecretsOr(func(store credstore.Store) {
+		for i := range cfg.Servers {
+			s := &cfg.Servers[i]
+			if s.Password == "" {
+				continue
+			}
+			if err := store.Set(s.Name, s.Password); err != nil {
+				dialog.ShowError(a.Pages, "Storing passwords failed: "+err.Error(), func() {
+					a.closeDialog("error")
+				})
+				a.ModalOpen = true
+				a.TviewApp.SetFocus(a.Pages)
+				return
+			}
+			s.Password = ""
+			s.StoredPassword = true
+		}
+		a.saveConfigWithServers(cfg)
+	}, func() {
+		dialog.ShowError(a.Pages, "The credential vault is locked; the configuration was not saved.", func() {
+			a.closeDialog("error")
+		})
+		a.ModalOpen = true
+		a.TviewApp.SetFocus(a.Pages)
+	})
+}
@@ -12,8 +12,15 @@ import (
 // SetupKeyBindings configures global key handling for the application.
 func (a *App) SetupKeyBindings() {
 	a.TviewApp.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		// Modal dialog open — let it handle all keys
+		// Modal dialog open — let it handle all keys. In the editor Ctrl+C
+		// is passed on as a copy of the event, as tview quits on the
+		// original and the editor would lose its changes.
 		if a.ModalOpen {
+			if event.Key() == tcell.KeyCtrlC {
+				if name, _ := a.Pages.GetFrontPage(); name == "editor" {
+					return tcell.NewEventKey(event.Key(), event.Rune(), event.Modifiers())
+				}
+			}
 			return event
 		}
 
@@ -121,6 +128,14 @@ func (a *App) SetupKeyBindings() {
 			a.ShowQuickPathsDialog()
 			return nil
 
+		case tcell.KeyCtrlG:
+			a.GoTo()
+			return nil
+
+		case tcell.KeyCtrlQ:
+			a.ToggleQuickView()
+			return nil
+
 		case tcell.KeyRight:
 			p := a.GetActivePanel()
 			if p.Mode == panel.ModeBrief {
@@ -190,9 +205,15 @@ func (a *App) SetupKeyBindings() {
 	// Panel table selection change handler
 	a.LeftPanel.Table.SetSelectionChangedFunc(func(row, col int) {
 		a.LeftPanel.HandleSelectionChanged(row, col)
+		if a.activePanel == 0 {
+			a.updateQuickView()
+		}
 	})
 	a.RightPanel.Table.SetSelectionChangedFunc(func(row, col int) {
 		a.RightPanel.HandleSelectionChanged(row, col)
+		if a.activePanel == 1 {
+			a.updateQuickView()
+		}
 	})
 
 	// Double-click = Enter. Two detection methods:
@@ -222,6 +243,12 @@ func (a *App) SetupKeyBindings() {
 			if a.ModalOpen || a.MenuActive {
 				return action, event
 			}
+			// A click on the quick view only focuses it; its file listing
+			// is hidden, so there is nothing to open or select.
+			if p.Mode == panel.ModeQuickView {
+				a.lastClickTime = time.Time{}
+				return action, event
+			}
 
 			switch action {
 			case tview.MouseLeftDoubleClick:
@@ -297,4 +324,5 @@ func (a *App) switchPanel() {
 func (a *App) updatePanelStates() {
 	a.LeftPanel.SetActive(a.activePanel == 0)
 	a.RightPanel.SetActive(a.activePanel == 1)
+	a.updateQuickView()
 }
@@ -0,0 +1,384 @@
+package app
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/feherkaroly/vc/internal/model"
+	"github.com/feherkaroly/vc/internal/panel"
+	"github.com/feherkaroly/vc/internal/textenc"
+	"github.com/feherkaroly/vc/internal/vfs"
+)
+
+const (
+	// quickViewHead is how much of a file quick view reads.
+	quickViewHead = 16 << 10
+	// quickViewRows is the least number of lines a preview is made of, so
+	// it still fills the panel after the terminal grows.
+	quickViewRows = 100
+)
+
+// ToggleQuickView handles Ctrl+Q: while on, the inactive panel previews the
+// entry under the active panel's cursor.
+func (a *App) ToggleQuickView() {
+	a.QuickView = !a.QuickView
+	if !a.QuickView {
+		a.stopQuickView()
+		a.LeftPanel.SetQuickView(false)
+		a.RightPanel.SetQuickView(false)
+		return
+	}
+	a.updateQuickView()
+}
+
+// quickViewIn turns quick view on in p, from the Left or Right menu, or
+// off if p shows it already.
+func (a *App) quickViewIn(p *panel.Panel) {
+	if a.QuickView && p.Mode == panel.ModeQuickView {
+		a.ToggleQuickView()
+		return
+	}
+	if p == a.GetActivePanel() {
+		a.switchPanel()
+	}
+	if !a.QuickView {
+		a.ToggleQuickView()
+	}
+}
+
+// setPanelMode lists files in p in mode m, leaving quick view if p was
+// showing it.
+func (a *App) setPanelMode(p *panel.Panel, m panel.DisplayMode) {
+	if p.Mode == panel.ModeQuickView {
+		a.ToggleQuickView()
+	}
+	p.Mode = m
+	a.SaveConfig()
+	a.DeactivateMenu()
+}
+
+// stopQuickView cancels building the preview shown last.
+func (a *App) stopQuickView() {
+	if a.quickViewCancel != nil {
+		a.quickViewCancel()
+		a.quickViewCancel = nil
+	}
+	a.quickViewKey = ""
+}
+
+// updateQuickView shows the active panel's current entry in the inactive
+// panel when quick view is on. It is called whenever the cursor moves or
+// the panels switch; the preview is built in the background and only when
+// the entry changed.
+func (a *App) updateQuickView() {
+	if !a.QuickView {
+		return
+	}
+	src, dst := a.GetActivePanel(), a.GetInactivePanel()
+	src.SetQuickView(false)
+	dst.SetQuickView(true)
+
+	e := src.CurrentEntry()
+	if e == nil {
+		a.stopQuickView()
+		dst.ShowPreview(nil)
+		return
+	}
+	path := src.CurrentPath()
+	key := fmt.Sprintf("%p %s %d %d", dst, path, e.Size, e.ModTime.UnixNano())
+	if key == a.quickViewKey {
+		return
+	}
+	a.stopQuickView()
+	a.quickViewKey = key
+	ctx, cancel := context.WithCancel(context.Background())
+	a.quickViewCancel = cancel
+
+	// A slow preview says it is loading after a moment; a fast one just
+	// replaces the last without flashing the message.
+	shown := false
+	entry, fsys := *e, src.FS
+	loading := time.AfterFunc(200*time.Millisecond, func() {
+		a.TviewApp.QueueUpdateDraw(func() {
+			if !shown && ctx.Err() == nil {
+				dst.ShowPreview(&panel.Preview{Name: entry.Name, Info: "Loading..."})
+			}
+		})
+	})
+	show := func(pv *panel.Preview) {
+		loading.Stop()
+		a.TviewApp.QueueUpdateDraw(func() {
+			if ctx.Err() == nil {
+				shown = true
+				dst.ShowPreview(pv)
+			}
+		})
+	}
+	_, _, width, height := dst.Table.GetInnerRect()
+	go buildPreview(ctx, fsys, path, entry, width, max(height, quickViewRows), show)
+}
+
+// buildPreview works out the preview of the entry at path and passes it to
+// show. Directory totals are passed on as they are counted.
+func buildPreview(ctx context.Context, fsys vfs.FileSystem, path string, e model.FileEntry, width, rows int, show func(*panel.Preview)) {
+	pv := panel.Preview{Name: e.Name}
+	var err error
+	switch {
+	case e.IsDir:
+		previewDir(ctx, fsys, path, e, show)
+		return
+	case isArchiveName(e.Name):
+		pv.Lines, pv.Info, err = previewArchive(ctx, fsys, path, e, rows)
+	default:
+		pv.Lines, pv.Info, err = previewFile(fsys, path, e, width, rows)
+	}
+	if err != nil {
+		pv.Lines, pv.Info = []string{"Cannot preview: " + err.Error()}, ""
+	}
+	show(&pv)
+}
+
+// previewDir shows the number of files and directories under path and
+// their total size, updating the counts while walking the tree.
+func previewDir(ctx context.Context, fsys vfs.FileSystem, path string, e model.FileEntry, show func(*panel.Preview)) {
+	var files, dirs int64
+	var size int64
+	report := func(done bool, err error) {
+		lines := []string{
+			"Directory:    " + e.Name,
+			"Files:        " + panel.FormatNumber(files),
+			"Directories:  " + panel.FormatNumber(dirs),
+			"Total size:   " + panel.FormatBytes(size),
+			"              " + panel.FormatNumber(size) + " bytes",
+		}
+		if !e.ModTime.IsZero() {
+			lines = append(lines, "Modified:     "+panel.FormatTime(e.ModTime))
+		}
+		switch {
+		case err != nil:
+			lines = append(lines, "", "Cannot read: "+err.Error())
+		case !done:
+			lines = append(lines, "", "Counting...")
+		}
+		info := fmt.Sprintf("%s in %d file(s)", panel.FormatBytes(size), files)
+		show(&panel.Preview{Name: e.Name, Lines: lines, Info: info})
+	}
+
+	report(false, nil)
+	last := time.Now()
+	err := fsys.Walk(path, func(p string, info vfs.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if p == path {
+			return err
+		}
+		if err != nil {
+			return nil // skip what cannot be read
+		}
+		if info.IsDir {
+			dirs++
+		} else {
+			files++
+			size += info.Size
+		}
+		if time.Since(last) > 250*time.Millisecond {
+			last = time.Now()
+			report(false, nil)
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return
+	}
+	report(true, err)
+}
+
+// isArchiveName reports whether quick view lists the file's contents.
+func isArchiveName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// previewArchive lists the entries of a zip or tar archive. Tar archives
+// are read only as far as the listing goes.
+func previewArchive(ctx context.Context, fsys vfs.FileSystem, path string, e model.FileEntry, rows int) ([]string, string, error) {
+	lines := []string{fmt.Sprintf("%12s  %-16s  %s", "Size", "Modified", "Name")}
+	entry := func(size int64, mod time.Time, name string) string {
+		return fmt.Sprintf("%12s  %-16s  %s", panel.FormatNumber(size), panel.FormatTime(mod), name)
+	}
+
+	if strings.HasSuffix(strings.ToLower(e.Name), ".zip") {
+		f, err := vfs.OpenRandom(fsys, path)
+		if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		// Closing the file makes a read that is still downloading fail, so
+		// moving on to another file doesn't wait for this one.
+		stop := context.AfterFunc(ctx, func() { f.Close() })
+		defer stop()
+		r, err := zip.NewReader(f, e.Size)
+		if err != nil {
+			return nil, "", err
+		}
+		var total uint64
+		for i, zf := range r.File {
+			total += zf.UncompressedSize64
+			if i < rows {
+				lines = append(lines, entry(int64(zf.UncompressedSize64), zf.Modified, zf.Name))
+			}
+		}
+		return lines, fmt.Sprintf("%d file(s), %s unpacked", len(r.File), panel.FormatBytes(int64(total))), nil
+	}
+
+	rc, err := fsys.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+	// Next reads through each entry's contents, which can take long for
+	// a large remote archive, so cancelling closes the stream under it.
+	stop := context.AfterFunc(ctx, func() { rc.Close() })
+	defer stop()
+	br := bufio.NewReader(rc)
+	var tr *tar.Reader
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, "", err
+		}
+		defer gr.Close()
+		tr = tar.NewReader(gr)
+	} else {
+		tr = tar.NewReader(br)
+	}
+	for n := 0; ; n++ {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		h, err := tr.Next()
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		if err == io.EOF {
+			return lines, fmt.Sprintf("%d file(s)", n), nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if n == rows {
+			return lines, fmt.Sprintf("first %d entries", n), nil
+		}
+		lines = append(lines, entry(h.Size, h.ModTime, h.Name))
+	}
+}
+
+// previewFile shows the start of a file: as text when it looks like text,
+// as a hex dump otherwise.
+func previewFile(fsys vfs.FileSystem, path string, e model.FileEntry, width, rows int) ([]string, string, error) {
+	rc, err := fsys.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	head, err := io.ReadAll(io.LimitReader(rc, quickViewHead))
+	rc.Close()
+	if err != nil {
+		return nil, "", err
+	}
+
+	size := panel.FormatBytes(e.Size)
+	enc := textenc.Detect(head[:min(len(head), 8192)])
+	if enc.Unit() == 1 && bytes.IndexByte(head, 0) >= 0 {
+		return hexLines(head, width, rows), size + "  binary", nil
+	}
+	return textLines(head, enc, int64(len(head)) < e.Size, rows), size + "  " + enc.Name, nil
+}
+
+// textLines decodes the first rows lines of head. With partial the last
+// line may be cut off, so it is left out.
+func textLines(head []byte, enc *textenc.Encoding, partial bool, rows int) []string {
+	text, _ := enc.Decode(head[len(enc.BOM(head)):], 0)
+	lines := strings.Split(string(text), "\n")
+	if partial && len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	lines = lines[:min(len(lines), rows)]
+	for i, l := range lines {
+		lines[i] = printable(strings.TrimSuffix(l, "\r"))
+	}
+	return lines
+}
+
+// printable expands tabs and replaces control characters and invalid
+// UTF-8 with dots.
+func printable(s string) string {
+	var sb strings.Builder
+	col := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == '\t':
+			n := 8 - col%8
+			sb.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		case r == utf8.RuneError && size <= 1, r < 0x20, r == 0x7f:
+			r = '.'
+		}
+		sb.WriteRune(r)
+		col++
+	}
+	return sb.String()
+}
+
+// hexLines dumps the first rows lines of data with as many bytes per line
+// as fit in width columns:
+//
+//	00000000  48 65 6c 6c 6f 2c 20 77  Hello, w
+func hexLines(data []byte, width, rows int) []string {
+	n := 16
+	for n > 4 && 8+1+3*n+(n-1)/8+2+n > width {
+		n /= 2
+	}
+	var lines []string
+	for off := 0; off < len(data) && len(lines) < rows; off += n {
+		chunk := data[off:min(off+n, len(data))]
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%08X ", off)
+		for i := 0; i < n; i++ {
+			if i > 0 && i%8 == 0 {
+				sb.WriteByte(' ')
+			}
+			if i < len(chunk) {
+				fmt.Fprintf(&sb, " %02x", chunk[i])
+			} else {
+				sb.WriteString("   ")
+			}
+		}
+		sb.WriteString("  ")
+		for _, b := range chunk {
+			if b < 0x20 || b > 0x7e {
+				b = '.'
+			}
+			sb.WriteByte(b)
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
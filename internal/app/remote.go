@@ -0,0 +1,58 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/feherkaroly/vc/internal/dialog"
+	"github.com/feherkaroly/vc/internal/vfs"
+	"github.com/feherkaroly/vc/internal/viewer"
+)
+
+// RunRemoteCommand asks for a shell command, runs it in the active remote
+// panel's directory and shows the output in the viewer.
+func (a *App) RunRemoteCommand() {
+	p := a.GetActivePanel()
+	runner, ok := vfs.Unwrap(p.FS).(vfs.CommandRunner)
+	if !p.IsRemote() || !ok {
+		dialog.ShowError(a.Pages, "Running commands requires an SSH connection.", func() {
+			a.closeDialog("error")
+		})
+		a.ModalOpen = true
+		a.TviewApp.SetFocus(a.Pages)
+		return
+	}
+
+	dialog.ShowInput(a.Pages, "Run command", "Command on "+p.ConnectedServer+":", "", func(cmd string) {
+		a.closeDialog("input")
+		if strings.TrimSpace(cmd) == "" {
+			return
+		}
+
+		dir := p.Path
+		spinner := a.showSpinner(cmd)
+		go func() {
+			out, err := runner.RunCommand(dir, cmd)
+			a.removeSpinner(spinner)
+
+			a.TviewApp.QueueUpdateDraw(func() {
+				text := string(out)
+				if err != nil {
+					if text != "" && !strings.HasSuffix(text, "\n") {
+						text += "\n"
+					}
+					text += "[" + err.Error() + "]\n"
+				}
+				v := viewer.NewFromText(p.ConnectedServer+": "+cmd, text)
+				v.SetDoneFunc(func() {
+					a.closeDialog("viewer")
+					p.Refresh()
+				})
+				a.showDialog("viewer", v)
+			})
+		}()
+	}, func() {
+		a.closeDialog("input")
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
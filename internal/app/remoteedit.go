@@ -0,0 +1,167 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/feherkaroly/vc/internal/dialog"
+	"github.com/feherkaroly/vc/internal/fileops"
+	"github.com/feherkaroly/vc/internal/panel"
+	"github.com/feherkaroly/vc/internal/vfs"
+)
+
+// remoteEdit is a remote file being edited through a local temp copy. It
+// keeps the filesystem the file came from, as the panel may be disconnected
+// or moved elsewhere while the file is downloaded, edited or checked.
+type remoteEdit struct {
+	p          *panel.Panel
+	fs         vfs.FileSystem
+	session    *vfs.Session // keeps the connection open until the upload; may be nil
+	name       string
+	remotePath string
+	localPath  string
+	tmpDir     string
+	before     vfs.FileInfo
+}
+
+// done releases the connection. With keep the local copy is left in place
+// for the user.
+func (e *remoteEdit) done(keep bool) {
+	if e.session != nil {
+		e.session.Release()
+	}
+	if !keep {
+		os.RemoveAll(e.tmpDir)
+	}
+}
+
+// editRemoteFile downloads a remote file into a private temp directory,
+// opens it in $EDITOR and uploads it again if it was changed. If the file
+// was also changed on the server in the meantime, the user decides whether
+// to overwrite it.
+func (a *App) editRemoteFile(p *panel.Panel, name string) {
+	e := &remoteEdit{p: p, fs: p.FS, name: name, remotePath: p.FS.Join(p.Path, name)}
+
+	tmpDir, err := os.MkdirTemp("", "vc-edit-")
+	if err != nil {
+		a.showEditError("Edit error: " + err.Error())
+		return
+	}
+	e.tmpDir, e.localPath = tmpDir, filepath.Join(tmpDir, name)
+	if p.Session != nil {
+		e.session = p.Session.Share()
+	}
+
+	spinner := a.showSpinner("Downloading " + name)
+	go func() {
+		before, err := e.fs.Stat(e.remotePath)
+		if err == nil {
+			// Not preserving the mode keeps the local copy writable
+			err = fileops.Copy(context.Background(), e.fs, e.remotePath, vfs.NewLocalFS(), e.localPath, false, nil)
+		}
+		var sum []byte
+		if err == nil {
+			sum, err = fileHash(e.localPath)
+		}
+		e.before = before
+		a.removeSpinner(spinner)
+
+		a.TviewApp.QueueUpdateDraw(func() {
+			if err != nil {
+				e.done(false)
+				a.showEditError("Download error: " + err.Error())
+				return
+			}
+
+			a.runEditor(e.localPath)
+
+			after, err := fileHash(e.localPath)
+			if err != nil || bytes.Equal(sum, after) {
+				e.done(false)
+				return
+			}
+
+			a.checkRemoteEdited(e)
+		})
+	}()
+}
+
+// checkRemoteEdited compares the remote file with what was downloaded, to
+// catch edits made by others, and uploads the edited copy. The server is
+// asked in the background, as it may be slow to answer.
+func (a *App) checkRemoteEdited(e *remoteEdit) {
+	spinner := a.showSpinner("Checking " + e.name)
+	go func() {
+		current, err := e.fs.Stat(e.remotePath)
+		a.removeSpinner(spinner)
+
+		a.TviewApp.QueueUpdateDraw(func() {
+			if err == nil && (current.Size != e.before.Size || !current.ModTime.Equal(e.before.ModTime)) {
+				msg := e.name + " was changed on the server while you were editing.\n\nOverwrite it with your version?"
+				dialog.ShowConfirm(a.Pages, "Remote file changed", msg, func(yes bool) {
+					a.closeDialog("confirm")
+					if !yes {
+						e.done(true)
+						a.showEditError("Your changes were not uploaded. They are kept in " + e.localPath)
+						return
+					}
+					a.uploadEdited(e)
+				})
+				a.ModalOpen = true
+				a.TviewApp.SetFocus(a.Pages)
+				return
+			}
+			a.uploadEdited(e)
+		})
+	}()
+}
+
+// uploadEdited copies an edited file back to the server. On failure the
+// local copy is kept and its location reported.
+func (a *App) uploadEdited(e *remoteEdit) {
+	spinner := a.showSpinner("Uploading " + e.name)
+	go func() {
+		// Upload with the remote file's original permissions
+		os.Chmod(e.localPath, e.before.Mode.Perm())
+		err := fileops.Copy(context.Background(), vfs.NewLocalFS(), e.localPath, e.fs, e.remotePath, true, nil)
+		a.removeSpinner(spinner)
+
+		a.TviewApp.QueueUpdateDraw(func() {
+			e.done(err != nil)
+			if err != nil {
+				a.showEditError("Upload error: " + err.Error() + "\n\nYour changes are kept in " + e.localPath)
+				return
+			}
+			if e.p.FS == e.fs {
+				e.p.Refresh()
+			}
+		})
+	}()
+}
+
+func (a *App) showEditError(msg string) {
+	dialog.ShowError(a.Pages, msg, func() {
+		a.closeDialog("error")
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
+
+// fileHash returns the SHA-256 of a local file.
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
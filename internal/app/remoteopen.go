@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/feherkaroly/vc/internal/fileops"
+	"github.com/feherkaroly/vc/internal/panel"
+	"github.com/feherkaroly/vc/internal/vfs"
+)
+
+// openRemoteFile downloads a remote file into the connection's cache
+// directory and opens it with the default application. An unchanged cached
+// copy is reused. The cache is removed when the connection is closed.
+func (a *App) openRemoteFile(p *panel.Panel, name string) {
+	if p.Session == nil {
+		a.showRemoteError("Open")
+		return
+	}
+	cacheDir, err := p.Session.CacheDir()
+	if err != nil {
+		a.showEditError("Open error: " + err.Error())
+		return
+	}
+
+	remoteFS := p.FS
+	remotePath := remoteFS.Join(p.Path, name)
+	localPath := filepath.Join(cacheDir, filepath.FromSlash(strings.TrimPrefix(remotePath, "/")))
+
+	spinner := a.showSpinner("Downloading " + name)
+	go func() {
+		info, err := remoteFS.Stat(remotePath)
+		if err == nil {
+			cached, statErr := os.Stat(localPath)
+			if statErr != nil || cached.Size() != info.Size || !cached.ModTime().Equal(info.ModTime) {
+				err = fileops.Copy(context.Background(), remoteFS, remotePath, vfs.NewLocalFS(), localPath, false, nil)
+				if err == nil {
+					os.Chtimes(localPath, info.ModTime, info.ModTime)
+				}
+			}
+		}
+		a.removeSpinner(spinner)
+
+		a.TviewApp.QueueUpdateDraw(func() {
+			if err != nil {
+				a.showEditError("Download error: " + err.Error())
+				return
+			}
+			openWithSystem(localPath)
+		})
+	}()
+}
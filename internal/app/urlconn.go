@@ -0,0 +1,187 @@
+package app
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/feherkaroly/vc/internal/config"
+	"github.com/feherkaroly/vc/internal/dialog"
+	"github.com/feherkaroly/vc/internal/panel"
+	"github.com/feherkaroly/vc/internal/vfs"
+)
+
+// GoTo handles Ctrl+G: jump to a path, or connect to a server URL such as
+// sftp://user@host/var/www.
+func (a *App) GoTo() {
+	p := a.GetActivePanel()
+	dialog.ShowInput(a.Pages, "Go to", "Path or URL:", p.Path, func(target string) {
+		a.closeDialog("input")
+		target = strings.TrimSpace(target)
+		if target == "" {
+			return
+		}
+		if config.IsServerURL(target) {
+			a.goToURL(p, target)
+			return
+		}
+		if !filepath.IsAbs(target) && p.FS.IsLocal() {
+			target = filepath.Join(p.Path, target)
+		}
+		p.NavigateTo(target, "")
+	}, func() {
+		a.closeDialog("input")
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
+
+// goToURL connects p to the server in rawURL and opens the path in it.
+func (a *App) goToURL(p *panel.Panel, rawURL string) {
+	a.openURL(rawURL, true, func(session *vfs.Session, remotePath string) {
+		a.attachSession(p, session, remotePath)
+	})
+}
+
+// openURL opens a session for a connection URL. A saved server with the same
+// protocol, host, port and user is used when there is one; otherwise the
+// connection is ad hoc and, once it works, the user may save it if offerSave
+// is set.
+func (a *App) openURL(rawURL string, offerSave bool, fn func(session *vfs.Session, remotePath string)) {
+	srv, remotePath, err := config.ParseServerURL(rawURL)
+	if err != nil {
+		dialog.ShowError(a.Pages, "URL error: "+err.Error(), func() {
+			a.closeDialog("error")
+		})
+		a.ModalOpen = true
+		a.TviewApp.SetFocus(a.Pages)
+		return
+	}
+
+	if saved, rest, ok := matchServer(config.Load().Servers, srv, remotePath); ok {
+		if srv.Password != "" {
+			saved.Password = srv.Password
+		}
+		a.withPassword(saved, func(saved config.ServerConfig) {
+			a.openSession(saved, func(session *vfs.Session) {
+				fn(session, rest)
+			})
+		})
+		return
+	}
+
+	connect := func(srv config.ServerConfig) {
+		a.openSession(srv, func(session *vfs.Session) {
+			if !offerSave {
+				fn(session, remotePath)
+				return
+			}
+			a.offerSaveServer(srv, func() {
+				fn(session, remotePath)
+			})
+		})
+	}
+
+	adhocDefaults(&srv)
+	if srv.Password != "" || srv.KeyPath != "" {
+		connect(srv)
+		return
+	}
+	dialog.ShowPasswordDialog(a.Pages, "Password for "+srv.Name, false, func(password string) {
+		a.closeDialog("password")
+		srv.Password = password
+		connect(srv)
+	}, func() {
+		a.closeDialog("password")
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
+
+// offerSaveServer asks whether to add an ad-hoc server to the server list,
+// then calls done either way. Each server is offered once per session.
+func (a *App) offerSaveServer(srv config.ServerConfig, done func()) {
+	key := config.ServerURL(srv, "/")
+	if a.saveOffered[key] {
+		done()
+		return
+	}
+	if a.saveOffered == nil {
+		a.saveOffered = map[string]bool{}
+	}
+	a.saveOffered[key] = true
+
+	dialog.ShowConfirm(a.Pages, "Save server", "Add "+srv.Name+" to the server list?", func(yes bool) {
+		a.closeDialog("confirm")
+		if !yes {
+			done()
+			return
+		}
+		a.storeServerPassword(config.ServerConfig{}, &srv, func() {
+			cfg := config.Load()
+			cfg.Servers = append(cfg.Servers, srv)
+			a.saveConfigWithServers(cfg)
+			done()
+		})
+	})
+	a.ModalOpen = true
+	a.TviewApp.SetFocus(a.Pages)
+}
+
+// matchServer finds the saved server an ad-hoc config refers to. For WebDAV
+// the saved root path must be a prefix of the URL path; the remainder is
+// returned as the path to open.
+func matchServer(servers []config.ServerConfig, adhoc config.ServerConfig, remotePath string) (config.ServerConfig, string, bool) {
+	for _, srv := range servers {
+		if srv.IsSeparator() || srv.Protocol != adhoc.Protocol ||
+			!strings.EqualFold(srv.Host, adhoc.Host) || srv.Port != adhoc.Port {
+			continue
+		}
+		if adhoc.User != "" && srv.User != adhoc.User {
+			continue
+		}
+		if srv.Protocol == "webdav" || srv.Protocol == "webdavs" {
+			root := path.Clean("/" + srv.RootPath)
+			full := path.Clean("/" + adhoc.RootPath)
+			if root != "/" && full != root && !strings.HasPrefix(full, root+"/") {
+				continue
+			}
+			return srv, path.Clean("/" + strings.TrimPrefix(full, root)), true
+		}
+		return srv, remotePath, true
+	}
+	return config.ServerConfig{}, "", false
+}
+
+// adhocDefaults fills in credentials a URL usually leaves out: anonymous
+// FTP, and the user's default SSH key.
+func adhocDefaults(srv *config.ServerConfig) {
+	switch srv.Protocol {
+	case "ftp", "ftps", "ftps-implicit":
+		if srv.User == "" {
+			srv.User = "anonymous"
+			srv.Password = "anonymous"
+		}
+	case "sftp":
+		if srv.User == "" {
+			if u := os.Getenv("USER"); u != "" {
+				srv.User = u
+			}
+		}
+		if srv.Password != "" {
+			return
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			key := filepath.Join(home, ".ssh", name)
+			if _, err := os.Stat(key); err == nil {
+				srv.KeyPath = key
+				return
+			}
+		}
+	}
+}
@@ -10,16 +10,25 @@ type PanelConfig struct {
 	Mode     int    `json:"mode"`
 	SortMode int    `json:"sort_mode"`
 	Path     string `json:"path,omitempty"`
+	Server   string `json:"server,omitempty"` // server name or URL when Path is remote
 }
 
 type ServerConfig struct {
 	Name     string `json:"name"`
-	Protocol string `json:"protocol"` // "sftp", "ftp", "ftps"
+	Protocol string `json:"protocol"` // "sftp", "ftp", "ftps", "ftps-implicit", "webdav", "webdavs", "s3", "s3-http", "smb"
 	Host     string `json:"host"`
-	Port     int    `json:"port,omitempty"` // 0 = default (22/21)
+	Port     int    `json:"port,omitempty"` // 0 = default (22/21/990/80/443/445)
 	User     string `json:"user"`
-	Password string `json:"password,omitempty"`
+	Password string `json:"password,omitempty"` // legacy plaintext; migrated to the credential store
 	KeyPath  string `json:"key_path,omitempty"`
+
+	StoredPassword bool   `json:"stored_password,omitempty"` // password is kept in the credential store
+	TLSFingerprint string `json:"tls_fingerprint,omitempty"` // pinned SHA-256 of the FTPS/HTTPS server certificate
+	RootPath       string `json:"root_path,omitempty"`       // WebDAV URL path, e.g. /remote.php/dav/files/me
+	Region         string `json:"region,omitempty"`          // S3 region; User/Password hold the access/secret key
+	Domain         string `json:"domain,omitempty"`          // SMB/NTLM domain
+	MaxConnections int    `json:"max_connections,omitempty"` // FTP connection pool size; 0 = default (3)
+	RateLimit      int    `json:"rate_limit,omitempty"`      // transfer limit in KB/s; 0 = unlimited
 }
 
 type Config struct {
@@ -28,7 +37,12 @@ type Config struct {
 	ActivePanel      int               `json:"active_panel"`
 	Servers          []ServerConfig    `json:"servers,omitempty"`
 	QuickPaths       map[string]string `json:"quick_paths,omitempty"`
-	CopyPreserveMode bool             `json:"copy_preserve_mode"`
+	CopyPreserveMode bool              `json:"copy_preserve_mode"`
+	DirectTransfer   bool              `json:"direct_transfer,omitempty"`  // copy server to server, bypassing this machine
+	CredentialStore  string            `json:"credential_store,omitempty"` // "keyring", "vault" or "" (auto)
+	RateLimit        int               `json:"rate_limit,omitempty"`       // global transfer limit in KB/s; 0 = unlimited
+	HexWidth         int               `json:"hex_width,omitempty"`        // bytes per line in the viewer's hex mode; 0 = 16
+	Editor           string            `json:"editor,omitempty"`           // "internal", "external" or "" ($EDITOR when it is set)
 }
 
 // IsSeparator returns true if this server entry is a visual separator.
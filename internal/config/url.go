@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// urlSchemes maps URL schemes to ServerConfig protocols.
+var urlSchemes = map[string]string{
+	"sftp":          "sftp",
+	"ssh":           "sftp",
+	"ftp":           "ftp",
+	"ftps":          "ftps",
+	"ftps-implicit": "ftps-implicit",
+	"webdav":        "webdav",
+	"dav":           "webdav",
+	"webdavs":       "webdavs",
+	"davs":          "webdavs",
+	"s3":            "s3",
+	"s3-http":       "s3-http",
+	"smb":           "smb",
+}
+
+// ServerURL formats srv and a remote path as a URL accepted by
+// ParseServerURL. The password is never included.
+func ServerURL(srv ServerConfig, remotePath string) string {
+	u := url.URL{Scheme: srv.Protocol, Host: srv.Host, Path: remotePath}
+	if srv.Port != 0 {
+		u.Host = net.JoinHostPort(srv.Host, strconv.Itoa(srv.Port))
+	} else if strings.Contains(srv.Host, ":") {
+		u.Host = "[" + srv.Host + "]"
+	}
+	if srv.User != "" {
+		u.User = url.User(srv.User)
+	}
+	if srv.RootPath != "" {
+		u.Path = path.Join("/", srv.RootPath, remotePath)
+	}
+	return u.String()
+}
+
+// IsServerURL reports whether s looks like a connection URL rather than a path.
+func IsServerURL(s string) bool {
+	scheme, _, ok := strings.Cut(s, "://")
+	if !ok {
+		return false
+	}
+	_, known := urlSchemes[strings.ToLower(scheme)]
+	return known
+}
+
+// ParseServerURL turns a URL such as sftp://user@host:2222/var/www into an
+// unsaved server config and the remote path to open. For WebDAV the URL path
+// is the endpoint, so it becomes RootPath and the returned path is "/".
+func ParseServerURL(s string) (ServerConfig, string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return ServerConfig{}, "", err
+	}
+	protocol, ok := urlSchemes[strings.ToLower(u.Scheme)]
+	if !ok {
+		return ServerConfig{}, "", fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return ServerConfig{}, "", fmt.Errorf("missing host in %q", s)
+	}
+
+	srv := ServerConfig{
+		Name:     u.Host,
+		Protocol: protocol,
+		Host:     u.Hostname(),
+		User:     u.User.Username(),
+	}
+	if u.User.Username() != "" {
+		srv.Name = u.User.Username() + "@" + u.Host
+	}
+	if pw, ok := u.User.Password(); ok {
+		srv.Password = pw
+	}
+	if p := u.Port(); p != "" {
+		srv.Port, err = strconv.Atoi(p)
+		if err != nil {
+			return ServerConfig{}, "", fmt.Errorf("invalid port %q", p)
+		}
+	}
+
+	remotePath := path.Clean("/" + u.Path)
+	if protocol == "webdav" || protocol == "webdavs" {
+		if remotePath != "/" {
+			srv.RootPath = remotePath
+		}
+		remotePath = "/"
+	}
+	return srv, remotePath, nil
+}
+
+// Server returns the saved server with the given name.
+func (c *Config) Server(name string) (ServerConfig, bool) {
+	for _, srv := range c.Servers {
+		if srv.Name == name && !srv.IsSeparator() {
+			return srv, true
+		}
+	}
+	return ServerConfig{}, false
+}
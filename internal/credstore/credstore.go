@@ -0,0 +1,48 @@
+package credstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Store keeps server passwords outside of config.json.
+type Store interface {
+	// Get returns the secret stored for server, or ErrNotFound.
+	Get(server string) (string, error)
+	Set(server, secret string) error
+	Delete(server string) error
+}
+
+var (
+	ErrNotFound = errors.New("credential not found")
+	ErrLocked   = errors.New("credential vault is locked")
+)
+
+// Open returns the credential store selected by kind: "keyring" for the OS
+// keyring, "vault" for the encrypted local vault. An empty kind picks the
+// keyring when one is reachable and falls back to the vault otherwise.
+func Open(kind string) Store {
+	switch kind {
+	case "keyring":
+		return NewKeyring()
+	case "vault":
+		return NewVault(DefaultVaultPath())
+	}
+	if KeyringAvailable() {
+		return NewKeyring()
+	}
+	return NewVault(DefaultVaultPath())
+}
+
+// NeedsUnlock returns true if s must be unlocked with a master password before use.
+func NeedsUnlock(s Store) bool {
+	v, ok := s.(*Vault)
+	return ok && !v.Unlocked()
+}
+
+// DefaultVaultPath returns the location of the encrypted vault next to config.json.
+func DefaultVaultPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "vc", "vault.enc")
+}
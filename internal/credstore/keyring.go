@@ -0,0 +1,44 @@
+package credstore
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+const keyringService = "vc"
+
+// Keyring stores secrets in the OS keyring: the Secret Service over D-Bus on
+// Linux, the Keychain on macOS and the Credential Manager on Windows.
+type Keyring struct{}
+
+// NewKeyring returns a keyring-backed store.
+func NewKeyring() *Keyring {
+	return &Keyring{}
+}
+
+// KeyringAvailable returns true if the OS keyring service can be reached.
+func KeyringAvailable() bool {
+	_, err := keyring.Get(keyringService, "vc-probe")
+	return err == nil || errors.Is(err, keyring.ErrNotFound)
+}
+
+func (k *Keyring) Get(server string) (string, error) {
+	secret, err := keyring.Get(keyringService, server)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	return secret, err
+}
+
+func (k *Keyring) Set(server, secret string) error {
+	return keyring.Set(keyringService, server, secret)
+}
+
+func (k *Keyring) Delete(server string) error {
+	err := keyring.Delete(keyringService, server)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package credstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Vault is an encrypted local credential store protected by a master password.
+// File format: [16 byte salt][12 byte nonce][AES-256-GCM ciphertext of a JSON object]
+type Vault struct {
+	path string
+
+	mu      sync.Mutex
+	salt    []byte
+	key     []byte // nil while locked
+	secrets map[string]string
+}
+
+// NewVault returns a locked vault backed by the file at path.
+func NewVault(path string) *Vault {
+	return &Vault{path: path}
+}
+
+// Exists returns true if the vault file has already been created.
+func (v *Vault) Exists() bool {
+	_, err := os.Stat(v.path)
+	return err == nil
+}
+
+// Unlocked returns true once the master password has been supplied.
+func (v *Vault) Unlocked() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.key != nil
+}
+
+// Unlock decrypts the vault with the master password.
+// If the vault does not exist yet, it is created with that password.
+func (v *Vault) Unlock(master string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	data, err := os.ReadFile(v.path)
+	if os.IsNotExist(err) {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		v.salt = salt
+		v.key = deriveKey(master, salt)
+		v.secrets = make(map[string]string)
+		return v.save()
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 16+12 {
+		return fmt.Errorf("invalid vault file")
+	}
+	salt := data[:16]
+	nonce := data[16 : 16+12]
+	key := deriveKey(master, salt)
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	plaintext, err := gcm.Open(nil, nonce, data[16+12:], nil)
+	if err != nil {
+		return fmt.Errorf("wrong master password")
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("invalid vault contents: %w", err)
+	}
+
+	v.salt = salt
+	v.key = key
+	v.secrets = secrets
+	return nil
+}
+
+func (v *Vault) Get(server string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return "", ErrLocked
+	}
+	secret, ok := v.secrets[server]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (v *Vault) Set(server, secret string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+	v.secrets[server] = secret
+	return v.save()
+}
+
+func (v *Vault) Delete(server string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+	if _, ok := v.secrets[server]; !ok {
+		return nil
+	}
+	delete(v.secrets, server)
+	return v.save()
+}
+
+// save encrypts the secrets with a fresh nonce and atomically replaces the vault file.
+func (v *Vault) save() error {
+	plaintext, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	out := make([]byte, 0, len(v.salt)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, v.salt...)
+	out = append(out, nonce...)
+	out = gcm.Seal(out, nonce, plaintext, nil)
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return err
+	}
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.path)
+}
+
+// deriveKey uses the same Argon2id parameters as file encryption (F2).
+func deriveKey(master string, salt []byte) []byte {
+	return argon2.IDKey([]byte(master), salt, 1, 64*1024, 4, 32)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -46,6 +46,8 @@ func newHelpBox(onClose func()) *helpBox {
 			" Other",
 			" ──────────────────────────────────",
 			" Ctrl+N         Quick paths",
+			" Ctrl+G         Go to path / URL",
+			" Ctrl+Q         Quick view",
 			" F9             Menu",
 			" F10            Quit",
 			"",
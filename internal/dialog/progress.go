@@ -19,6 +19,10 @@ type ProgressDialog struct {
 	text   *tview.TextView
 	button *tview.Button
 	onDone func()
+
+	last    fileops.Progress
+	limit   int64             // bytes per second, 0 = unlimited
+	onLimit func(faster bool) // set when the rate limit can be changed with +/-
 }
 
 // NewProgressDialog creates a progress dialog with the given title (e.g. "Copying", "Moving").
@@ -48,7 +52,7 @@ func NewProgressDialog(title string, onAbort func()) *ProgressDialog {
 	buttonRow.SetBackgroundColor(theme.ColorDialogBg)
 
 	inner := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(d.text, 5, 0, false).
+		AddItem(d.text, 6, 0, false).
 		AddItem(buttonRow, 1, 0, true)
 	inner.SetBackgroundColor(theme.ColorDialogBg)
 	inner.SetBorder(true)
@@ -63,6 +67,16 @@ func NewProgressDialog(title string, onAbort func()) *ProgressDialog {
 			}
 			return nil
 		}
+		if d.onLimit != nil && event.Key() == tcell.KeyRune {
+			switch event.Rune() {
+			case '+', '=':
+				d.onLimit(true)
+				return nil
+			case '-':
+				d.onLimit(false)
+				return nil
+			}
+		}
 		return event
 	})
 
@@ -73,15 +87,26 @@ func NewProgressDialog(title string, onAbort func()) *ProgressDialog {
 			AddItem(nil, 0, 1, false).
 			AddItem(inner, 40, 0, true).
 			AddItem(nil, 0, 1, false),
-			9, 0, true).
+			10, 0, true).
 		AddItem(nil, 0, 1, false)
 
 	d.Update(fileops.Progress{})
 	return d
 }
 
+// SetLimit shows the current transfer rate limit. A non-nil onChange enables
+// the +/- keys, which call it to request a higher or lower limit.
+func (d *ProgressDialog) SetLimit(bytesPerSec int64, onChange func(faster bool)) {
+	d.limit = bytesPerSec
+	if onChange != nil {
+		d.onLimit = onChange
+	}
+	d.Update(d.last)
+}
+
 // Update refreshes the dialog with new progress data.
 func (d *ProgressDialog) Update(p fileops.Progress) {
+	d.last = p
 	pct := p.Percent()
 	bar := buildProgressBar(pct, progressBarWidth)
 
@@ -106,6 +131,15 @@ func (d *ProgressDialog) Update(p fileops.Progress) {
 		lines = append(lines, fmt.Sprintf("File %d of %d", p.FileIndex, p.FileCount))
 	}
 
+	// Rate limit
+	if d.onLimit != nil {
+		limit := "unlimited"
+		if d.limit > 0 {
+			limit = formatSize(d.limit) + "/s"
+		}
+		lines = append(lines, "Limit: "+limit+"  (+/-)")
+	}
+
 	d.text.SetText(strings.Join(lines, "\n"))
 }
 
@@ -28,9 +28,17 @@ func ShowServerEdit(pages *tview.Pages, title string, srv config.ServerConfig, o
 	if srv.Port != 0 {
 		portStr = strconv.Itoa(srv.Port)
 	}
+	connStr := ""
+	if srv.MaxConnections != 0 {
+		connStr = strconv.Itoa(srv.MaxConnections)
+	}
+	rateStr := ""
+	if srv.RateLimit != 0 {
+		rateStr = strconv.Itoa(srv.RateLimit)
+	}
 
 	// Protocol selection
-	protocols := []string{"sftp", "ftp", "ftps"}
+	protocols := []string{"sftp", "ftp", "ftps", "ftps-implicit", "webdav", "webdavs", "s3", "s3-http", "smb"}
 	initialProtocol := 0
 	for i, p := range protocols {
 		if p == srv.Protocol {
@@ -45,7 +53,17 @@ func ShowServerEdit(pages *tview.Pages, title string, srv config.ServerConfig, o
 	form.AddInputField("Port:", portStr, 10, nil, nil)
 	form.AddInputField("User:", srv.User, 30, nil, nil)
 	form.AddPasswordField("Password:", srv.Password, 30, '*', nil)
+	if srv.StoredPassword {
+		form.GetFormItem(5).(*tview.InputField).SetPlaceholder("(stored)")
+	}
 	form.AddInputField("Key Path:", srv.KeyPath, 40, nil, nil)
+	form.AddInputField("TLS Fingerprint:", srv.TLSFingerprint, 40, nil, nil)
+	form.AddInputField("FTP Connections:", connStr, 10, nil, nil)
+	form.AddInputField("WebDAV Path:", srv.RootPath, 40, nil, nil)
+	form.AddInputField("S3 Region:", srv.Region, 20, nil, nil)
+	form.AddInputField("SMB Domain:", srv.Domain, 30, nil, nil)
+	form.AddInputField("Limit (KB/s):", rateStr, 10, nil, nil)
+	form.GetFormItem(12).(*tview.InputField).SetPlaceholder("unlimited")
 
 	form.AddButton("Save", func() {
 		name := form.GetFormItem(0).(*tview.InputField).GetText()
@@ -55,21 +73,41 @@ func ShowServerEdit(pages *tview.Pages, title string, srv config.ServerConfig, o
 		user := form.GetFormItem(4).(*tview.InputField).GetText()
 		password := form.GetFormItem(5).(*tview.InputField).GetText()
 		keyPath := form.GetFormItem(6).(*tview.InputField).GetText()
+		fingerprint := form.GetFormItem(7).(*tview.InputField).GetText()
+		connText := form.GetFormItem(8).(*tview.InputField).GetText()
+		rootPath := form.GetFormItem(9).(*tview.InputField).GetText()
+		region := form.GetFormItem(10).(*tview.InputField).GetText()
+		domain := form.GetFormItem(11).(*tview.InputField).GetText()
+		rateText := form.GetFormItem(12).(*tview.InputField).GetText()
 
 		port := 0
 		if portText != "" {
 			port, _ = strconv.Atoi(portText)
 		}
+		maxConns := 0
+		if connText != "" {
+			maxConns, _ = strconv.Atoi(connText)
+		}
+		rate := 0
+		if rateText != "" {
+			rate, _ = strconv.Atoi(rateText)
+		}
 
-		onSave(config.ServerConfig{
-			Name:     name,
-			Protocol: protocol,
-			Host:     host,
-			Port:     port,
-			User:     user,
-			Password: password,
-			KeyPath:  keyPath,
-		})
+		updated := srv
+		updated.Name = name
+		updated.Protocol = protocol
+		updated.Host = host
+		updated.Port = port
+		updated.User = user
+		updated.Password = password
+		updated.KeyPath = keyPath
+		updated.TLSFingerprint = fingerprint
+		updated.MaxConnections = maxConns
+		updated.RootPath = rootPath
+		updated.Region = region
+		updated.Domain = domain
+		updated.RateLimit = rate
+		onSave(updated)
 	})
 	form.AddButton("Cancel", func() {
 		onCancel()
@@ -84,7 +122,7 @@ func ShowServerEdit(pages *tview.Pages, title string, srv config.ServerConfig, o
 	})
 
 	dialogWidth := 60
-	dialogHeight := 19
+	dialogHeight := 31
 
 	flex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(nil, 0, 1, false).
@@ -0,0 +1,188 @@
+package editor
+
+import "strings"
+
+// pos is a position in the text: a line and a rune index within it.
+type pos struct {
+	line, col int
+}
+
+func (p pos) before(q pos) bool {
+	return p.line < q.line || p.line == q.line && p.col < q.col
+}
+
+// order returns a and b with the earlier one first.
+func order(a, b pos) (pos, pos) {
+	if b.before(a) {
+		return b, a
+	}
+	return a, b
+}
+
+// edit is one change to the text, recorded so it can be undone. Edits made
+// by the same command share a group and are undone together.
+type edit struct {
+	insert bool // text was inserted at at, rather than removed from it
+	at     pos
+	text   string
+	group  int
+}
+
+// buffer holds the text being edited as lines of runes, with undo and redo
+// history.
+type buffer struct {
+	lines [][]rune
+	undo  []edit
+	redo  []edit
+	group int
+}
+
+func newBuffer(text string) *buffer {
+	b := &buffer{}
+	for _, l := range strings.Split(text, "\n") {
+		b.lines = append(b.lines, []rune(l))
+	}
+	return b
+}
+
+// String returns the text with lines joined by nl.
+func (b *buffer) String(nl string) string {
+	parts := make([]string, len(b.lines))
+	for i, l := range b.lines {
+		parts[i] = string(l)
+	}
+	return strings.Join(parts, nl)
+}
+
+// begin starts a new undo group; the edits that follow are undone together.
+func (b *buffer) begin() {
+	b.group++
+}
+
+// end returns the position after the last character.
+func (b *buffer) end() pos {
+	last := len(b.lines) - 1
+	return pos{last, len(b.lines[last])}
+}
+
+// clamp moves p inside the text.
+func (b *buffer) clamp(p pos) pos {
+	p.line = max(0, min(p.line, len(b.lines)-1))
+	p.col = max(0, min(p.col, len(b.lines[p.line])))
+	return p
+}
+
+// text returns the text between from and to.
+func (b *buffer) text(from, to pos) string {
+	from, to = order(from, to)
+	if from.line == to.line {
+		return string(b.lines[from.line][from.col:to.col])
+	}
+	var sb strings.Builder
+	sb.WriteString(string(b.lines[from.line][from.col:]))
+	for i := from.line + 1; i < to.line; i++ {
+		sb.WriteByte('\n')
+		sb.WriteString(string(b.lines[i]))
+	}
+	sb.WriteByte('\n')
+	sb.WriteString(string(b.lines[to.line][:to.col]))
+	return sb.String()
+}
+
+// insert inserts text at at and returns the position after it.
+func (b *buffer) insert(at pos, text string) pos {
+	if text == "" {
+		return at
+	}
+	b.record(edit{insert: true, at: at, text: text})
+	return b.doInsert(at, text)
+}
+
+// remove deletes the text between from and to and returns it.
+func (b *buffer) remove(from, to pos) string {
+	from, to = order(from, to)
+	if from == to {
+		return ""
+	}
+	text := b.text(from, to)
+	b.record(edit{at: from, text: text})
+	b.doRemove(from, to)
+	return text
+}
+
+func (b *buffer) record(e edit) {
+	e.group = b.group
+	b.undo = append(b.undo, e)
+	b.redo = nil
+}
+
+func (b *buffer) doInsert(at pos, text string) pos {
+	parts := strings.Split(text, "\n")
+	line := b.lines[at.line]
+	tail := append([]rune(nil), line[at.col:]...)
+	first := append(line[:at.col:at.col], []rune(parts[0])...)
+	if len(parts) == 1 {
+		b.lines[at.line] = append(first, tail...)
+		return pos{at.line, at.col + len([]rune(parts[0]))}
+	}
+
+	added := make([][]rune, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		added = append(added, []rune(p))
+	}
+	last := len(added) - 1
+	endCol := len(added[last])
+	added[last] = append(added[last], tail...)
+	b.lines[at.line] = first
+	b.lines = append(b.lines[:at.line+1], append(added, b.lines[at.line+1:]...)...)
+	return pos{at.line + len(parts) - 1, endCol}
+}
+
+func (b *buffer) doRemove(from, to pos) {
+	joined := append(b.lines[from.line][:from.col:from.col], b.lines[to.line][to.col:]...)
+	b.lines[from.line] = joined
+	b.lines = append(b.lines[:from.line+1], b.lines[to.line+1:]...)
+}
+
+// endOf returns the position after text inserted at at.
+func endOf(at pos, text string) pos {
+	n := strings.Count(text, "\n")
+	if n == 0 {
+		return pos{at.line, at.col + len([]rune(text))}
+	}
+	return pos{at.line + n, len([]rune(text[strings.LastIndexByte(text, '\n')+1:]))}
+}
+
+// undoLast reverts the last group of edits and returns where the cursor
+// belongs, or false if there is nothing to undo.
+func (b *buffer) undoLast() (pos, bool) {
+	return b.replay(&b.undo, &b.redo, true)
+}
+
+// redoLast applies the last undone group again.
+func (b *buffer) redoLast() (pos, bool) {
+	return b.replay(&b.redo, &b.undo, false)
+}
+
+// replay moves the last group of edits from one history to the other,
+// reverting them when undoing and applying them again otherwise. Popping
+// reverses the order, so redo applies a group in its original order.
+func (b *buffer) replay(from, to *[]edit, revert bool) (pos, bool) {
+	if len(*from) == 0 {
+		return pos{}, false
+	}
+	group := (*from)[len(*from)-1].group
+	var cur pos
+	for len(*from) > 0 && (*from)[len(*from)-1].group == group {
+		e := (*from)[len(*from)-1]
+		*from = (*from)[:len(*from)-1]
+		*to = append(*to, e)
+		if e.insert == revert {
+			b.doRemove(e.at, endOf(e.at, e.text))
+			cur = e.at
+		} else {
+			cur = b.doInsert(e.at, e.text)
+		}
+	}
+	return cur, true
+}
@@ -0,0 +1,654 @@
+// Package editor implements vc's internal text editor. Files are read and
+// written through vfs.FileSystem, so remote files are edited in place.
+package editor
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
+
+	"github.com/feherkaroly/vc/internal/textenc"
+	"github.com/feherkaroly/vc/internal/theme"
+	"github.com/feherkaroly/vc/internal/vfs"
+)
+
+const tabWidth = 8
+
+// clipboard is shared by all editors, so text can be copied between files.
+var clipboard string
+
+// Editor is a full-screen text editor for one file.
+type Editor struct {
+	*tview.Box
+
+	fsys    vfs.FileSystem
+	path    string
+	mode    os.FileMode
+	size    int64     // on disk when loaded or last saved,
+	modTime time.Time // to notice changes made by others
+	enc     *textenc.Encoding
+	bom     []byte
+	crlf    bool
+
+	buf       *buffer
+	cur       pos
+	want      int  // display column kept when moving up and down
+	anchor    pos  // other end of the selection
+	selecting bool // anchor is set
+	marking   bool // F3 mark mode: moving the cursor extends the selection
+	typing    bool // the last key typed a character, which the next one joins for undo
+	overwrite bool
+
+	top, left     int
+	width, height int
+	keepCursor    bool // scroll to the cursor on the next draw
+
+	version int // counts edits; saved is the version last written
+	saved   int
+	saving  bool
+
+	prompt  *prompt
+	message string // shown on the status row until the next key
+
+	search     string
+	regex      bool
+	ignoreCase bool
+
+	doneFunc    func()
+	queueUpdate func(func())
+}
+
+// Open loads path from fsys for editing.
+func Open(fsys vfs.FileSystem, path string) (*Editor, error) {
+	box := tview.NewBox()
+	box.SetBorder(true)
+	box.SetBorderColor(theme.ColorActiveBorder)
+	box.SetBackgroundColor(theme.ColorPanelBg)
+	box.SetTitleColor(theme.ColorHeaderFg)
+
+	e := &Editor{Box: box, fsys: fsys, path: path, ignoreCase: true, keepCursor: true}
+	text, err := e.load()
+	if err != nil {
+		return nil, err
+	}
+	e.buf = newBuffer(text)
+	return e, nil
+}
+
+// SetDoneFunc sets the function called when the editor is closed.
+func (e *Editor) SetDoneFunc(f func()) {
+	e.doneFunc = f
+}
+
+// SetQueueUpdateFunc sets the function background work uses to update the
+// editor and redraw it, normally tview's Application.QueueUpdateDraw.
+// Without it files are saved in the foreground.
+func (e *Editor) SetQueueUpdateFunc(f func(func())) {
+	e.queueUpdate = f
+}
+
+// Modified reports whether the text has changes that are not saved.
+func (e *Editor) Modified() bool {
+	return e.version != e.saved
+}
+
+// close asks whether to save changes, then calls the done function.
+func (e *Editor) close() {
+	if e.saving {
+		e.message = "Still saving..."
+		return
+	}
+	done := func() {
+		if e.doneFunc != nil {
+			e.doneFunc()
+		}
+	}
+	if !e.Modified() {
+		done()
+		return
+	}
+	e.choose("Save changes to "+e.fsys.Base(e.path)+"? (y)es (n)o (c)ancel", func(r rune) {
+		switch unicode.ToLower(r) {
+		case 'y':
+			e.save(done)
+		case 'n':
+			done()
+		}
+	})
+}
+
+// status returns the status row shown when there is no message.
+func (e *Editor) status() string {
+	mode := "INS"
+	if e.overwrite {
+		mode = "OVR"
+	}
+	nl := "LF"
+	if e.crlf {
+		nl = "CRLF"
+	}
+	s := fmt.Sprintf("Ln %d/%d  Col %d  %s  %s %s", e.cur.line+1, len(e.buf.lines), e.colOf(e.cur)+1, mode, e.enc.Name, nl)
+	if e.marking {
+		s += "  MARK"
+	}
+	return s + "    F2 Save  F3 Mark  F4 Replace  F7 Find  Esc Close"
+}
+
+// runeWidth returns how many columns r takes when it starts at column col.
+func runeWidth(r rune, col int) int {
+	switch {
+	case r == '\t':
+		return tabWidth - col%tabWidth
+	case r < 0x20, r == 0x7f:
+		return 1
+	}
+	return uniseg.StringWidth(string(r))
+}
+
+// colOf returns the display column of p.
+func (e *Editor) colOf(p pos) int {
+	col := 0
+	for _, r := range e.buf.lines[p.line][:p.col] {
+		col += runeWidth(r, col)
+	}
+	return col
+}
+
+// colAt returns the rune index in line that is shown at display column x.
+func (e *Editor) colAt(line, x int) int {
+	col := 0
+	for i, r := range e.buf.lines[line] {
+		w := runeWidth(r, col)
+		if col+w > x {
+			return i
+		}
+		col += w
+	}
+	return len(e.buf.lines[line])
+}
+
+// selection returns the selected range, or the cursor twice.
+func (e *Editor) selection() (pos, pos) {
+	if !e.selecting {
+		return e.cur, e.cur
+	}
+	return order(e.anchor, e.cur)
+}
+
+// scrollToCursor scrolls so the cursor is on screen.
+func (e *Editor) scrollToCursor() {
+	h := max(e.height, 1)
+	if e.cur.line < e.top {
+		e.top = e.cur.line
+	} else if e.cur.line >= e.top+h {
+		e.top = e.cur.line - h + 1
+	}
+	col := e.colOf(e.cur)
+	w := max(e.width, 2)
+	if col < e.left {
+		e.left = max(0, col-w/4)
+	} else if col >= e.left+w {
+		e.left = col - w + w/4
+	}
+}
+
+// selStyle highlights the selection.
+var selStyle = tcell.StyleDefault.Background(theme.ColorCursorBg).Foreground(theme.ColorCursorFg)
+
+func (e *Editor) Draw(screen tcell.Screen) {
+	title := e.path
+	if e.Modified() {
+		title += " *"
+	}
+	e.SetTitle(" " + title + " ")
+	e.Box.DrawForSubclass(screen, e)
+
+	x, y, width, height := e.GetInnerRect()
+	height--
+	e.drawStatus(screen, x, y+height, width)
+	e.width, e.height = width, height
+	if e.keepCursor {
+		e.scrollToCursor()
+	}
+	style := tcell.StyleDefault.Background(theme.ColorPanelBg).Foreground(theme.ColorNormalFile)
+
+	from, to := e.selection()
+	for row := 0; row < height && e.top+row < len(e.buf.lines); row++ {
+		ln := e.top + row
+		col := 0
+		for i, r := range e.buf.lines[ln] {
+			w := runeWidth(r, col)
+			st := style
+			if p := (pos{ln, i}); !p.before(from) && p.before(to) {
+				st = selStyle
+			}
+			ch := r
+			switch {
+			case r == '\t':
+				ch = ' '
+			case r < 0x20, r == 0x7f:
+				ch = '.'
+			}
+			sx := col - e.left
+			if sx >= width {
+				break
+			}
+			if r == '\t' {
+				for k := max(0, -sx); k < w && sx+k < width; k++ {
+					screen.SetContent(x+sx+k, y+row, ' ', nil, st)
+				}
+			} else if w > 0 && sx >= 0 && sx+w <= width {
+				screen.SetContent(x+sx, y+row, ch, nil, st)
+			}
+			col += w
+		}
+		// A selected line break shows as one selected cell.
+		if ln >= from.line && ln < to.line {
+			if sx := col - e.left; sx >= 0 && sx < width {
+				screen.SetContent(x+sx, y+row, ' ', nil, selStyle)
+			}
+		}
+	}
+
+	if e.prompt == nil && e.HasFocus() {
+		cx, cy := e.colOf(e.cur)-e.left, e.cur.line-e.top
+		if cx >= 0 && cx < width && cy >= 0 && cy < height {
+			screen.ShowCursor(x+cx, y+cy)
+		}
+	}
+}
+
+// moveTo moves the cursor to p, extending the selection with extend or in
+// mark mode and dropping it otherwise.
+func (e *Editor) moveTo(p pos, extend bool) {
+	e.setCursor(p, extend)
+	e.want = e.colOf(e.cur)
+}
+
+// moveLines moves the cursor n lines down, or up for negative n, keeping
+// its display column.
+func (e *Editor) moveLines(n int, extend bool) {
+	line := max(0, min(e.cur.line+n, len(e.buf.lines)-1))
+	e.setCursor(pos{line, e.colAt(line, e.want)}, extend)
+}
+
+func (e *Editor) setCursor(p pos, extend bool) {
+	extend = extend || e.marking
+	if extend && !e.selecting {
+		e.anchor, e.selecting = e.cur, true
+	}
+	if !extend {
+		e.selecting = false
+	}
+	e.cur = e.buf.clamp(p)
+	e.typing = false
+}
+
+// prevPos returns the position before p, at the end of the previous line when
+// p starts a line.
+func (e *Editor) prevPos(p pos) pos {
+	if p.col > 0 {
+		return pos{p.line, p.col - 1}
+	}
+	if p.line > 0 {
+		return pos{p.line - 1, len(e.buf.lines[p.line-1])}
+	}
+	return p
+}
+
+// nextPos returns the position after p.
+func (e *Editor) nextPos(p pos) pos {
+	if p.col < len(e.buf.lines[p.line]) {
+		return pos{p.line, p.col + 1}
+	}
+	if p.line+1 < len(e.buf.lines) {
+		return pos{p.line + 1, 0}
+	}
+	return p
+}
+
+func isWord(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordLeft returns the start of the word before p.
+func (e *Editor) wordLeft(p pos) pos {
+	if p.col == 0 {
+		return e.prevPos(p)
+	}
+	line := e.buf.lines[p.line]
+	i := p.col
+	for i > 0 && !isWord(line[i-1]) {
+		i--
+	}
+	for i > 0 && isWord(line[i-1]) {
+		i--
+	}
+	return pos{p.line, i}
+}
+
+// wordRight returns the end of the word after p.
+func (e *Editor) wordRight(p pos) pos {
+	line := e.buf.lines[p.line]
+	if p.col == len(line) {
+		return e.nextPos(p)
+	}
+	i := p.col
+	for i < len(line) && !isWord(line[i]) {
+		i++
+	}
+	for i < len(line) && isWord(line[i]) {
+		i++
+	}
+	return pos{p.line, i}
+}
+
+// home returns the first non-blank character of the line, or its start
+// when p is already there.
+func (e *Editor) home(p pos) pos {
+	line := e.buf.lines[p.line]
+	i := 0
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	if p.col == i {
+		i = 0
+	}
+	return pos{p.line, i}
+}
+
+// changed records that the text was edited.
+func (e *Editor) changed() {
+	e.version++
+	e.want = e.colOf(e.cur)
+}
+
+// deleteSelection removes the selected text, if any.
+func (e *Editor) deleteSelection() bool {
+	from, to := e.selection()
+	e.selecting, e.marking = false, false
+	if from == to {
+		return false
+	}
+	e.buf.remove(from, to)
+	e.cur = from
+	return true
+}
+
+// insertText replaces the selection with text as one undo step.
+func (e *Editor) insertText(text string) {
+	e.buf.begin()
+	e.deleteSelection()
+	e.cur = e.buf.insert(e.cur, text)
+	e.typing = false
+	e.changed()
+}
+
+// typeRune inserts, or in overwrite mode replaces, one typed character.
+// Consecutive characters are undone together.
+func (e *Editor) typeRune(r rune) {
+	if !e.typing || e.selecting {
+		e.buf.begin()
+	}
+	e.deleteSelection()
+	if e.overwrite && e.cur.col < len(e.buf.lines[e.cur.line]) {
+		e.buf.remove(e.cur, pos{e.cur.line, e.cur.col + 1})
+	}
+	e.cur = e.buf.insert(e.cur, string(r))
+	e.typing = r != ' '
+	e.changed()
+}
+
+// newline breaks the line at the cursor, indenting the new line like the
+// current one.
+func (e *Editor) newline() {
+	line := e.buf.lines[e.cur.line]
+	i := 0
+	for i < min(len(line), e.cur.col) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	e.insertText("\n" + string(line[:i]))
+}
+
+// deleteRange removes [from, to) as one undo step.
+func (e *Editor) deleteRange(from, to pos) {
+	if from == to {
+		return
+	}
+	e.buf.begin()
+	e.buf.remove(from, to)
+	e.cur = from
+	e.typing = false
+	e.changed()
+}
+
+func (e *Editor) backspace() {
+	if e.selecting {
+		e.cut(false)
+		return
+	}
+	e.deleteRange(e.prevPos(e.cur), e.cur)
+}
+
+func (e *Editor) deleteForward() {
+	if e.selecting {
+		e.cut(false)
+		return
+	}
+	e.deleteRange(e.cur, e.nextPos(e.cur))
+}
+
+// copySelection puts the selected text on the clipboard.
+func (e *Editor) copySelection() bool {
+	from, to := e.selection()
+	if from == to {
+		e.message = "Nothing selected"
+		return false
+	}
+	clipboard = e.buf.text(from, to)
+	e.marking = false
+	return true
+}
+
+// cut removes the selection, putting it on the clipboard with toClipboard.
+func (e *Editor) cut(toClipboard bool) {
+	if toClipboard && !e.copySelection() {
+		return
+	}
+	e.buf.begin()
+	if e.deleteSelection() {
+		e.typing = false
+		e.changed()
+	}
+}
+
+func (e *Editor) paste() {
+	if clipboard == "" {
+		e.message = "The clipboard is empty"
+		return
+	}
+	e.insertText(clipboard)
+}
+
+func (e *Editor) undo() {
+	if p, ok := e.buf.undoLast(); ok {
+		e.afterHistory(p)
+	} else {
+		e.message = "Nothing to undo"
+	}
+}
+
+func (e *Editor) redo() {
+	if p, ok := e.buf.redoLast(); ok {
+		e.afterHistory(p)
+	} else {
+		e.message = "Nothing to redo"
+	}
+}
+
+func (e *Editor) afterHistory(p pos) {
+	e.selecting, e.marking, e.typing = false, false, false
+	e.cur = e.buf.clamp(p)
+	e.changed()
+}
+
+// toggleMark starts or ends F3 mark mode.
+func (e *Editor) toggleMark() {
+	e.marking = !e.marking
+	if e.marking {
+		e.anchor, e.selecting = e.cur, true
+	}
+}
+
+// InputHandler handles editing keys.
+func (e *Editor) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return e.WrapInputHandler(func(event *tcell.EventKey, _ func(p tview.Primitive)) {
+		e.keepCursor = true
+		if e.prompt != nil {
+			e.promptKey(event)
+			return
+		}
+		e.message = ""
+		shift := event.Modifiers()&tcell.ModShift != 0
+		ctrl := event.Modifiers()&tcell.ModCtrl != 0
+		page := max(e.height-1, 1)
+
+		switch event.Key() {
+		case tcell.KeyEscape:
+			if e.selecting {
+				e.selecting, e.marking = false, false
+			} else {
+				e.close()
+			}
+		case tcell.KeyF10:
+			e.close()
+		case tcell.KeyF2, tcell.KeyCtrlS:
+			if !e.saving {
+				e.save(nil)
+			}
+		case tcell.KeyF3:
+			e.toggleMark()
+		case tcell.KeyF4:
+			e.askReplace()
+		case tcell.KeyF7, tcell.KeyCtrlF:
+			if shift {
+				e.findNext()
+			} else {
+				e.askSearch()
+			}
+		case tcell.KeyF19: // Shift+F7 on most terminals
+			e.findNext()
+		case tcell.KeyCtrlG:
+			e.askGoTo()
+
+		case tcell.KeyLeft:
+			if ctrl {
+				e.moveTo(e.wordLeft(e.cur), shift)
+			} else {
+				e.moveTo(e.prevPos(e.cur), shift)
+			}
+		case tcell.KeyRight:
+			if ctrl {
+				e.moveTo(e.wordRight(e.cur), shift)
+			} else {
+				e.moveTo(e.nextPos(e.cur), shift)
+			}
+		case tcell.KeyUp:
+			e.moveLines(-1, shift)
+		case tcell.KeyDown:
+			e.moveLines(1, shift)
+		case tcell.KeyPgUp:
+			e.top = max(0, e.top-page)
+			e.moveLines(-page, shift)
+		case tcell.KeyPgDn:
+			e.top = max(0, min(e.top+page, len(e.buf.lines)-page))
+			e.moveLines(page, shift)
+		case tcell.KeyHome:
+			if ctrl {
+				e.moveTo(pos{}, shift)
+			} else {
+				e.moveTo(e.home(e.cur), shift)
+			}
+		case tcell.KeyEnd:
+			if ctrl {
+				e.moveTo(e.buf.end(), shift)
+			} else {
+				e.moveTo(pos{e.cur.line, len(e.buf.lines[e.cur.line])}, shift)
+			}
+		case tcell.KeyCtrlA:
+			e.anchor, e.selecting = pos{}, true
+			e.cur = e.buf.end()
+
+		case tcell.KeyEnter:
+			e.newline()
+		case tcell.KeyTab:
+			e.insertText("\t")
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			e.backspace()
+		case tcell.KeyDelete:
+			if shift {
+				e.cut(true)
+			} else {
+				e.deleteForward()
+			}
+		case tcell.KeyInsert:
+			switch {
+			case ctrl:
+				e.copySelection()
+			case shift:
+				e.paste()
+			default:
+				e.overwrite = !e.overwrite
+			}
+		case tcell.KeyCtrlC:
+			e.copySelection()
+		case tcell.KeyCtrlX:
+			e.cut(true)
+		case tcell.KeyCtrlV:
+			e.paste()
+		case tcell.KeyCtrlZ:
+			e.undo()
+		case tcell.KeyCtrlY:
+			e.redo()
+		case tcell.KeyRune:
+			if event.Modifiers()&tcell.ModAlt == 0 {
+				e.typeRune(event.Rune())
+			}
+		}
+	})
+}
+
+// MouseHandler places the cursor on click and scrolls with the wheel.
+func (e *Editor) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return e.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (bool, tview.Primitive) {
+		if !e.InRect(event.Position()) {
+			return false, nil
+		}
+		switch action {
+		case tview.MouseScrollUp:
+			e.top = max(0, e.top-3)
+			e.keepCursor = false
+		case tview.MouseScrollDown:
+			e.top = max(0, min(e.top+3, len(e.buf.lines)-1))
+			e.keepCursor = false
+		case tview.MouseLeftDown:
+			setFocus(e)
+			x, y, _, _ := e.GetInnerRect()
+			mx, my := event.Position()
+			if e.prompt == nil && mx >= x && my >= y && my-y < e.height {
+				line := min(e.top+my-y, len(e.buf.lines)-1)
+				shift := event.Modifiers()&tcell.ModShift != 0
+				e.moveTo(pos{line, e.colAt(line, e.left+mx-x)}, shift)
+				e.keepCursor = true
+			}
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+}
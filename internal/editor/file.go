@@ -0,0 +1,182 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/feherkaroly/vc/internal/fileops"
+	"github.com/feherkaroly/vc/internal/textenc"
+	"github.com/feherkaroly/vc/internal/vfs"
+)
+
+// maxFileSize is the largest file the editor loads; it keeps the whole text
+// in memory.
+const maxFileSize = 32 << 20
+
+// load reads the file and decodes it to text, remembering the encoding,
+// byte order mark and line endings so save can write it back the same way.
+func (e *Editor) load() (string, error) {
+	info, err := e.fsys.Stat(e.path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir {
+		return "", fmt.Errorf("%s is a directory", e.path)
+	}
+	if info.Size > maxFileSize {
+		return "", fmt.Errorf("%s is too large to edit (%d MB at most)", e.fsys.Base(e.path), maxFileSize>>20)
+	}
+	e.mode, e.size, e.modTime = info.Mode, info.Size, info.ModTime
+
+	rc, err := e.fsys.Open(e.path)
+	if err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return "", err
+	}
+
+	head := data[:min(len(data), 8192)]
+	e.enc = textenc.Detect(head)
+	if e.enc.Unit() == 1 && bytes.IndexByte(head, 0) >= 0 {
+		return "", errors.New("binary files cannot be edited")
+	}
+	e.bom = e.enc.BOM(data)
+	data = data[len(e.bom):]
+	if e.enc == textenc.UTF8 && !utf8.Valid(data) {
+		// Latin-1 maps every byte to a character and back, so saving keeps
+		// the bytes that are not valid UTF-8.
+		e.enc = textenc.ByName("ISO-8859-1")
+		e.message = "Not valid UTF-8; editing as ISO-8859-1"
+	}
+	if e.enc != textenc.UTF8 {
+		if data, err = e.enc.NewDecoder().Bytes(data); err != nil {
+			return "", err
+		}
+	}
+
+	if i := bytes.IndexByte(data, '\n'); i > 0 && data[i-1] == '\r' {
+		e.crlf = true
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	return string(data), nil
+}
+
+// encode returns the file content for the current text.
+func (e *Editor) encode() ([]byte, error) {
+	nl := "\n"
+	if e.crlf {
+		nl = "\r\n"
+	}
+	data := []byte(e.buf.String(nl))
+	if e.enc != textenc.UTF8 {
+		var err error
+		if data, err = e.enc.NewEncoder().Bytes(data); err != nil {
+			return nil, fmt.Errorf("the text has characters %s cannot store", e.enc.Name)
+		}
+	}
+	return append(append([]byte(nil), e.bom...), data...), nil
+}
+
+// errChangedOnDisk is returned by write when the file was modified by
+// someone else since it was loaded or last saved.
+var errChangedOnDisk = errors.New("file changed on disk")
+
+// write stores data in the file and returns the file's new info. With
+// check it fails with errChangedOnDisk if someone else changed the file.
+// The data goes to a temporary file next to it that then replaces it, so a
+// failed write leaves the file as it was; a symlink is written through in
+// place, as replacing it would turn it into a copy. It runs in the
+// background, so it only reads fields that do not change.
+func (e *Editor) write(data []byte, check bool) (vfs.FileInfo, error) {
+	if check {
+		info, err := e.fsys.Stat(e.path)
+		if err == nil && (info.Size != e.size || !info.ModTime.Equal(e.modTime)) {
+			return vfs.FileInfo{}, errChangedOnDisk
+		}
+	}
+
+	target := e.fsys.Join(e.fsys.Dir(e.path), "."+e.fsys.Base(e.path)+".vc-save")
+	if fi, err := e.fsys.Lstat(e.path); err == nil && fi.Mode&os.ModeSymlink != 0 {
+		target = e.path
+	}
+	w, err := e.fsys.Create(target, e.mode)
+	if err != nil {
+		return vfs.FileInfo{}, err
+	}
+	if _, err := w.Write(data); err != nil {
+		vfs.Abort(w, err)
+		if target != e.path {
+			e.fsys.Remove(target)
+		}
+		return vfs.FileInfo{}, err
+	}
+	if err := w.Close(); err != nil {
+		if target != e.path {
+			e.fsys.Remove(target)
+		}
+		return vfs.FileInfo{}, err
+	}
+	if target != e.path {
+		if err := fileops.Replace(e.fsys, target, e.path); err != nil {
+			return vfs.FileInfo{}, err
+		}
+	}
+	info, _ := e.fsys.Stat(e.path)
+	return info, nil
+}
+
+// save writes the text to the file, asking first if the file was changed
+// by someone else. then is called after a successful save.
+func (e *Editor) save(then func()) {
+	data, err := e.encode()
+	if err != nil {
+		e.message = "Cannot save: " + err.Error()
+		return
+	}
+	e.store(data, true, then)
+}
+
+// store writes data in the background when the editor can update itself
+// from another goroutine, so saving to a slow server does not block; that
+// includes checking whether the file was changed by someone else.
+func (e *Editor) store(data []byte, check bool, then func()) {
+	version := e.version
+	done := func(info vfs.FileInfo, err error) {
+		e.saving = false
+		if errors.Is(err, errChangedOnDisk) {
+			e.choose("The file was changed by someone else since it was opened. Overwrite it? (y/n)", func(r rune) {
+				if r == 'y' || r == 'Y' {
+					e.store(data, false, then)
+				}
+			})
+			return
+		}
+		if err != nil {
+			e.message = "Save error: " + err.Error()
+			return
+		}
+		e.size, e.modTime = info.Size, info.ModTime
+		e.saved = version
+		e.message = "Saved"
+		if then != nil {
+			then()
+		}
+	}
+	e.saving = true
+	if e.queueUpdate == nil {
+		done(e.write(data, check))
+		return
+	}
+	e.message = "Saving..."
+	go func() {
+		info, err := e.write(data, check)
+		e.queueUpdate(func() { done(info, err) })
+	}()
+}
@@ -0,0 +1,90 @@
+package editor
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/feherkaroly/vc/internal/theme"
+)
+
+// prompt is a one-line input, or a question answered with a single key,
+// shown on the bottom row of the editor.
+type prompt struct {
+	label   string
+	text    []rune
+	onDone  func(text string)
+	onKey   func(r rune)         // set for questions; Esc cancels them
+	keys    map[tcell.Key]func() // extra keys, e.g. to toggle options
+	relabel func() string        // recomputes label after an extra key
+}
+
+// ask opens a prompt; onDone is called with the text when Enter is pressed.
+func (e *Editor) ask(label, text string, onDone func(text string)) {
+	e.prompt = &prompt{label: label, text: []rune(text), onDone: onDone}
+	e.message = ""
+}
+
+// choose asks a question; onKey is called with the key pressed to answer.
+func (e *Editor) choose(label string, onKey func(r rune)) {
+	e.prompt = &prompt{label: label, onKey: onKey}
+	e.message = ""
+}
+
+// promptKey handles a key while the prompt is open.
+func (e *Editor) promptKey(event *tcell.EventKey) {
+	p := e.prompt
+	if f, ok := p.keys[event.Key()]; ok {
+		f()
+		if p.relabel != nil {
+			p.label = p.relabel()
+		}
+		return
+	}
+	if p.onKey != nil {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			e.prompt = nil
+		case tcell.KeyRune:
+			e.prompt = nil
+			p.onKey(event.Rune())
+		}
+		return
+	}
+	switch event.Key() {
+	case tcell.KeyEscape:
+		e.prompt = nil
+	case tcell.KeyEnter:
+		e.prompt = nil
+		p.onDone(string(p.text))
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(p.text) > 0 {
+			p.text = p.text[:len(p.text)-1]
+		}
+	case tcell.KeyCtrlU:
+		p.text = nil
+	case tcell.KeyRune:
+		p.text = append(p.text, event.Rune())
+	}
+}
+
+// drawStatus draws the prompt, the last message or the cursor position on
+// row y.
+func (e *Editor) drawStatus(screen tcell.Screen, x, y, width int) {
+	style := tcell.StyleDefault.Background(theme.ColorDialogBg).Foreground(theme.ColorDialogFg)
+	for i := 0; i < width; i++ {
+		screen.SetContent(x+i, y, ' ', nil, style)
+	}
+	if e.prompt == nil {
+		text := e.message
+		if text == "" {
+			text = e.status()
+		}
+		tview.Print(screen, tview.Escape(text), x+1, y, width-1, tview.AlignLeft, theme.ColorDialogFg)
+		return
+	}
+	line := e.prompt.label + " " + string(e.prompt.text)
+	_, n := tview.Print(screen, tview.Escape(line), x+1, y, width-2, tview.AlignLeft, theme.ColorDialogFg)
+	if 1+n < width {
+		screen.ShowCursor(x+1+n, y)
+	}
+}
@@ -0,0 +1,205 @@
+package editor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// match is a found occurrence of the search pattern.
+type match struct {
+	from, to pos
+	line     string // the line it is in
+	idx      []int  // submatch byte offsets in line, for regex replacement
+}
+
+// compile builds the regular expression for the current search.
+func (e *Editor) compile() (*regexp.Regexp, error) {
+	expr := e.search
+	if !e.regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if e.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	return re, nil
+}
+
+// find returns the first non-empty match at or after start. With wrap the
+// search continues from the top of the text.
+func (e *Editor) find(re *regexp.Regexp, start pos, wrap bool) (match, bool) {
+	n := len(e.buf.lines)
+	limit := n - start.line
+	if wrap {
+		limit = n + 1
+	}
+	for i := 0; i < limit; i++ {
+		ln := (start.line + i) % n
+		line := string(e.buf.lines[ln])
+		minCol := 0
+		if i == 0 {
+			minCol = start.col
+		}
+		for _, idx := range re.FindAllStringSubmatchIndex(line, -1) {
+			if idx[1] == idx[0] {
+				continue
+			}
+			from := utf8.RuneCountInString(line[:idx[0]])
+			if from < minCol {
+				continue
+			}
+			to := from + utf8.RuneCountInString(line[idx[0]:idx[1]])
+			return match{from: pos{ln, from}, to: pos{ln, to}, line: line, idx: idx}, true
+		}
+	}
+	return match{}, false
+}
+
+// selectMatch selects m, leaving the cursor at its end.
+func (e *Editor) selectMatch(m match) {
+	e.marking = false
+	e.anchor, e.selecting = m.from, true
+	e.cur = m.to
+	e.want = e.colOf(e.cur)
+}
+
+// searchLabel returns a prompt label showing the active search options.
+func (e *Editor) searchLabel(label string) string {
+	var opts []string
+	if e.regex {
+		opts = append(opts, "regex")
+	}
+	if e.ignoreCase {
+		opts = append(opts, "ignore case")
+	}
+	if len(opts) > 0 {
+		label += " [" + strings.Join(opts, ", ") + "]"
+	}
+	return label + ":"
+}
+
+// askPattern prompts for a search pattern. Ctrl+R toggles regular
+// expressions and Ctrl+T case sensitivity.
+func (e *Editor) askPattern(label string, onDone func()) {
+	e.ask(e.searchLabel(label), e.search, func(text string) {
+		if text == "" {
+			return
+		}
+		e.search = text
+		onDone()
+	})
+	e.prompt.keys = map[tcell.Key]func(){
+		tcell.KeyCtrlR: func() { e.regex = !e.regex },
+		tcell.KeyCtrlT: func() { e.ignoreCase = !e.ignoreCase },
+	}
+	e.prompt.relabel = func() string { return e.searchLabel(label) }
+}
+
+func (e *Editor) askSearch() {
+	e.askPattern("Search", e.findNext)
+}
+
+// findNext selects the next match after the cursor, wrapping around at the
+// end of the text.
+func (e *Editor) findNext() {
+	if e.search == "" {
+		e.askSearch()
+		return
+	}
+	re, err := e.compile()
+	if err != nil {
+		e.message = err.Error()
+		return
+	}
+	m, ok := e.find(re, e.cur, true)
+	if !ok {
+		e.message = "Not found: " + e.search
+		return
+	}
+	if m.from.before(e.cur) {
+		e.message = "Search wrapped to the top"
+	}
+	e.selectMatch(m)
+}
+
+// askReplace asks for a pattern and its replacement, then goes through the
+// matches from the cursor to the end, asking about each.
+func (e *Editor) askReplace() {
+	e.askPattern("Replace", func() {
+		e.ask("Replace with:", "", func(with string) {
+			re, err := e.compile()
+			if err != nil {
+				e.message = err.Error()
+				return
+			}
+			e.buf.begin()
+			e.replaceNext(re, with, e.cur, 0)
+		})
+	})
+}
+
+// replaceNext finds the next match at or after start and asks whether to
+// replace it. count is the number replaced so far; all replacements of one
+// run are undone together.
+func (e *Editor) replaceNext(re *regexp.Regexp, with string, start pos, count int) {
+	m, ok := e.find(re, start, false)
+	if !ok {
+		e.message = fmt.Sprintf("Replaced %d occurrence(s)", count)
+		return
+	}
+	e.selectMatch(m)
+	e.choose("Replace? (y)es (n)o (a)ll (q)uit", func(r rune) {
+		switch unicode.ToLower(r) {
+		case 'y':
+			end := e.replace(re, m, with)
+			e.replaceNext(re, with, end, count+1)
+		case 'n':
+			e.replaceNext(re, with, m.to, count)
+		case 'a':
+			for ok {
+				start = e.replace(re, m, with)
+				count++
+				m, ok = e.find(re, start, false)
+			}
+			e.message = fmt.Sprintf("Replaced %d occurrence(s)", count)
+		default:
+			e.selecting = false
+			e.message = fmt.Sprintf("Replaced %d occurrence(s)", count)
+		}
+	})
+}
+
+// replace puts the replacement for m in its place and returns the position
+// after it. Regex replacements may refer to groups as $1.
+func (e *Editor) replace(re *regexp.Regexp, m match, with string) pos {
+	text := with
+	if e.regex {
+		text = string(re.ExpandString(nil, with, m.line, m.idx))
+	}
+	e.buf.remove(m.from, m.to)
+	e.cur = e.buf.insert(m.from, text)
+	e.selecting = false
+	e.changed()
+	return e.cur
+}
+
+// askGoTo prompts for a line number and moves the cursor there.
+func (e *Editor) askGoTo() {
+	e.ask("Go to line:", "", func(text string) {
+		n, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || n < 1 {
+			e.message = fmt.Sprintf("invalid line number %q", text)
+			return
+		}
+		e.moveTo(pos{min(n, len(e.buf.lines)) - 1, 0}, false)
+	})
+}
@@ -0,0 +1,94 @@
+package fileops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/text/transform"
+
+	"github.com/feherkaroly/vc/internal/textenc"
+	"github.com/feherkaroly/vc/internal/vfs"
+)
+
+// ConvertToUTF8 rewrites the file at path from enc to UTF-8. The converted
+// text is written to a temporary file next to it, which then replaces the
+// original, so a failed conversion leaves the file untouched. Should the
+// replacing fail, the error says where the converted copy was kept.
+func ConvertToUTF8(ctx context.Context, fsys vfs.FileSystem, path string, enc *textenc.Encoding) error {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if info.IsDir {
+		return fmt.Errorf("%s is a directory", path)
+	}
+
+	tmp := fsys.Join(fsys.Dir(path), "."+fsys.Base(path)+".vc-utf8")
+	if err := convertFile(ctx, fsys, path, tmp, enc, info.Mode); err != nil {
+		fsys.Remove(tmp)
+		return err
+	}
+	return Replace(fsys, tmp, path)
+}
+
+// Replace renames tmp to path, replacing the file there. Not every server
+// replaces an existing file on rename, so the original is moved aside and
+// the rename tried again. A rename that fails for another reason fails
+// there too, with the original still in place and tmp removed; should the
+// second rename fail, the error says where the new copy was kept.
+func Replace(fsys vfs.FileSystem, tmp, path string) error {
+	if err := fsys.Rename(tmp, path); err != nil {
+		orig := fsys.Join(fsys.Dir(path), "."+fsys.Base(path)+".vc-orig")
+		if fsys.Rename(path, orig) != nil {
+			fsys.Remove(tmp)
+			return err
+		}
+		if err := fsys.Rename(tmp, path); err != nil {
+			if fsys.Rename(orig, path) != nil {
+				return fmt.Errorf("%w; the original is kept in %s and the new copy in %s", err, orig, tmp)
+			}
+			return fmt.Errorf("%w; the new copy is kept in %s", err, tmp)
+		}
+		fsys.Remove(orig)
+	}
+	return nil
+}
+
+func convertFile(ctx context.Context, fsys vfs.FileSystem, src, dst string, enc *textenc.Encoding, mode os.FileMode) error {
+	sf, err := fsys.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+
+	df, err := fsys.Create(dst, mode)
+	if err != nil {
+		return err
+	}
+
+	r := transform.NewReader(sf, enc.NewDecoder())
+	buf := make([]byte, 256*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			df.Close()
+			return err
+		}
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			if _, err := df.Write(buf[:n]); err != nil {
+				df.Close()
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			df.Close()
+			return readErr
+		}
+	}
+	return df.Close()
+}
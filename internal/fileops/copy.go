@@ -2,6 +2,7 @@ package fileops
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 
@@ -12,6 +13,81 @@ import (
 // When preserveMode is true, the source file/directory permissions are preserved.
 // When false, default permissions are used (0666 for files, 0777 for dirs, modified by umask).
 func Copy(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.FileSystem, dst string, preserveMode bool, onProgress func(Progress)) error {
+	// Same remote connection: let the server copy if it can.
+	if srcFS == dstFS {
+		if c, ok := vfs.Unwrap(srcFS).(vfs.Copier); ok {
+			err := copyWithin(ctx, c, srcFS, src, dst, preserveMode, onProgress)
+			if err == nil || ctx.Err() != nil {
+				return err
+			}
+		}
+	}
+	return copyPath(ctx, srcFS, src, dstFS, dst, preserveMode, onProgress)
+}
+
+// copyWithin copies src to dst on the server. With progress reporting, a
+// directory is copied an entry at a time, so the dialog shows which one is
+// being copied instead of sitting at the start until the end.
+func copyWithin(ctx context.Context, c vfs.Copier, fsys vfs.FileSystem, src, dst string, preserveMode bool, onProgress func(Progress)) error {
+	if onProgress == nil {
+		return c.CopyWithin(ctx, src, dst, preserveMode)
+	}
+	info, err := fsys.Lstat(src)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", src, err)
+	}
+	if !info.IsDir {
+		onProgress(Progress{FileName: fsys.Base(src), Total: info.Size})
+		if err := c.CopyWithin(ctx, src, dst, preserveMode); err != nil {
+			return err
+		}
+		onProgress(Progress{FileName: fsys.Base(src), Total: info.Size, Done: info.Size})
+		return nil
+	}
+
+	dirMode := info.Mode
+	if !preserveMode {
+		dirMode = 0777
+	}
+	if err := fsys.MkdirAll(dst, dirMode); err != nil {
+		return err
+	}
+	entries, err := fsys.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		onProgress(Progress{FileName: entry.Name, Total: entry.Size})
+		if err := c.CopyWithin(ctx, fsys.Join(src, entry.Name), fsys.Join(dst, entry.Name), preserveMode); err != nil {
+			return err
+		}
+		onProgress(Progress{FileName: entry.Name, Total: entry.Size, Done: entry.Size})
+	}
+	return nil
+}
+
+// CopyDirect is like Copy, but when src and dst are on two different servers
+// that can talk to each other (SSH to SSH, FTP to FTP), the data is sent
+// from one server to the other without passing through this machine.
+// If that isn't possible, including when the servers can't reach or log in
+// to each other, it falls back to Copy; errors while copying are returned as
+// they are.
+func CopyDirect(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.FileSystem, dst string, preserveMode bool, onProgress func(Progress)) error {
+	if srcFS != dstFS && !srcFS.IsLocal() && !dstFS.IsLocal() {
+		if t, ok := vfs.Unwrap(srcFS).(vfs.DirectTransferer); ok {
+			err := t.TransferTo(ctx, src, vfs.Unwrap(dstFS), dst, preserveMode)
+			if !errors.Is(err, vfs.ErrNoDirectTransfer) {
+				return err
+			}
+		}
+	}
+	return Copy(ctx, srcFS, src, dstFS, dst, preserveMode, onProgress)
+}
+
+func copyPath(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.FileSystem, dst string, preserveMode bool, onProgress func(Progress)) error {
 	srcInfo, err := srcFS.Lstat(src)
 	if err != nil {
 		return fmt.Errorf("stat %s: %w", src, err)
@@ -45,8 +121,12 @@ func copyFile(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.F
 	}
 	defer df.Close()
 
+	// Remote streams are subject to the server and global rate limits.
+	limits := []*vfs.Limiter{vfs.LimiterOf(srcFS), vfs.LimiterOf(dstFS)}
+	throttled := limits[0] != nil || limits[1] != nil
+
 	// Fast path: use io.Copy which leverages sftp.File's concurrent ReadFrom/WriteTo
-	if onProgress == nil {
+	if onProgress == nil && !throttled {
 		_, err := io.Copy(df, sf)
 		return err
 	}
@@ -57,22 +137,37 @@ func copyFile(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.F
 	buf := make([]byte, 256*1024)
 	for {
 		if err := ctx.Err(); err != nil {
-			df.Close()
+			vfs.Abort(df, err)
 			dstFS.Remove(dst)
 			return err
 		}
 
-		n, readErr := sf.Read(buf)
+		// A throttled read is sized from the current rate, which the user
+		// may change during the copy.
+		chunk := buf
+		if throttled {
+			chunk = buf[:vfs.ChunkSize(len(buf), limits...)]
+		}
+		n, readErr := sf.Read(chunk)
 		if n > 0 {
+			if throttled {
+				if err := vfs.Wait(ctx, n, limits...); err != nil {
+					vfs.Abort(df, err)
+					dstFS.Remove(dst)
+					return err
+				}
+			}
 			if _, writeErr := df.Write(buf[:n]); writeErr != nil {
 				return writeErr
 			}
 			copied += int64(n)
-			onProgress(Progress{
-				FileName: srcFS.Base(src),
-				Total:    total,
-				Done:     copied,
-			})
+			if onProgress != nil {
+				onProgress(Progress{
+					FileName: srcFS.Base(src),
+					Total:    total,
+					Done:     copied,
+				})
+			}
 		}
 		if readErr == io.EOF {
 			break
@@ -112,7 +207,7 @@ func copyDir(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.Fi
 		srcPath := srcFS.Join(src, entry.Name)
 		dstPath := dstFS.Join(dst, entry.Name)
 
-		if err := Copy(ctx, srcFS, srcPath, dstFS, dstPath, preserveMode, onProgress); err != nil {
+		if err := copyPath(ctx, srcFS, srcPath, dstFS, dstPath, preserveMode, onProgress); err != nil {
 			return err
 		}
 	}
@@ -3,7 +3,14 @@ package fileops
 import "github.com/feherkaroly/vc/internal/vfs"
 
 // CalcDirSize recursively calculates the total size of a directory.
+// Remote filesystems that can compute it server-side are asked first.
 func CalcDirSize(fs vfs.FileSystem, path string) int64 {
+	if ds, ok := vfs.Unwrap(fs).(vfs.DirSizer); ok {
+		if size, err := ds.DirSize(path); err == nil {
+			return size
+		}
+	}
+
 	var total int64
 	fs.Walk(path, func(_ string, info vfs.FileInfo, err error) error {
 		if err != nil {
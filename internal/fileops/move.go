@@ -10,6 +10,17 @@ import (
 // If srcFS and dstFS are the same local filesystem, tries Rename first (fast path).
 // Falls back to copy+delete for cross-filesystem moves.
 func Move(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.FileSystem, dst string, onProgress func(Progress)) error {
+	return move(ctx, srcFS, src, dstFS, dst, Copy, onProgress)
+}
+
+// MoveDirect is like Move but copies with CopyDirect between two servers.
+func MoveDirect(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.FileSystem, dst string, onProgress func(Progress)) error {
+	return move(ctx, srcFS, src, dstFS, dst, CopyDirect, onProgress)
+}
+
+type copyFunc func(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.FileSystem, dst string, preserveMode bool, onProgress func(Progress)) error
+
+func move(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.FileSystem, dst string, copyFn copyFunc, onProgress func(Progress)) error {
 	// Ensure destination directory exists
 	if err := dstFS.MkdirAll(dstFS.Dir(dst), 0755); err != nil {
 		return err
@@ -24,7 +35,7 @@ func Move(ctx context.Context, srcFS vfs.FileSystem, src string, dstFS vfs.FileS
 	}
 
 	// Fallback: copy then delete
-	if err := Copy(ctx, srcFS, src, dstFS, dst, true, onProgress); err != nil {
+	if err := copyFn(ctx, srcFS, src, dstFS, dst, true, onProgress); err != nil {
 		// On cancel, clean up partial copy but keep source
 		if ctx.Err() != nil {
 			dstFS.RemoveAll(dst)
@@ -5,6 +5,7 @@ package menu
 type MenuDefs struct {
 	OnBriefMode    func()
 	OnFullMode     func()
+	OnQuickView    func()
 	OnSortName     func()
 	OnSortExt      func()
 	OnSortSize     func()
@@ -21,7 +22,9 @@ type MenuDefs struct {
 	OnExportConfig func()
 	OnImportConfig func()
 	OnQuickPaths   func()
+	OnGoTo         func()
 	OnCheckUpdate  func()
+	OnRunRemote    func()
 	OnSymlink      func()
 	OnRename       func()
 	OnChmod        func()
@@ -29,12 +32,19 @@ type MenuDefs struct {
 	OnDisconnect        func()
 	OnTogglePreserve    func()
 	CopyPreserveModeOn  bool
+	OnToggleDirect      func()
+	DirectTransferOn    bool
+	OnRateLimit         func()
+	OnConvertEncoding   func()
+	OnToggleEditor      func()
+	ExternalEditorOn    bool
 }
 
 func LeftMenuItems(defs *MenuDefs) []MenuItem {
 	return []MenuItem{
 		{Label: "Brief", Key: "", Action: defs.OnBriefMode, HotKey: 'B'},
 		{Label: "Full", Key: "", Action: defs.OnFullMode, HotKey: 'U'},
+		{Label: "Quick view", Key: "Ctrl+Q", Action: defs.OnQuickView, HotKey: 'Q'},
 		{IsSep: true},
 		{Label: "Sort by Name", Key: "", Action: defs.OnSortName, HotKey: 'N'},
 		{Label: "Sort by Ext", Key: "", Action: defs.OnSortExt, HotKey: 'E'},
@@ -68,6 +78,9 @@ func CommandsMenuItems(defs *MenuDefs) []MenuItem {
 		{Label: "Refresh", Key: "Ctrl+R", Action: defs.OnRefresh, HotKey: 'R'},
 		{IsSep: true},
 		{Label: "Quick paths", Key: "Ctrl+N", Action: defs.OnQuickPaths, HotKey: 'Q'},
+		{Label: "Go to path/URL", Key: "Ctrl+G", Action: defs.OnGoTo, HotKey: 'G'},
+		{Label: "Run command on remote", Key: "", Action: defs.OnRunRemote, HotKey: 'C'},
+		{Label: "Convert to UTF-8", Key: "", Action: defs.OnConvertEncoding, HotKey: 'T'},
 		{IsSep: true},
 		{Label: "Export config", Key: "", Action: defs.OnExportConfig, HotKey: 'E'},
 		{Label: "Import config", Key: "", Action: defs.OnImportConfig, HotKey: 'I'},
@@ -81,8 +94,19 @@ func OptionsMenuItems(defs *MenuDefs) []MenuItem {
 	if defs.CopyPreserveModeOn {
 		preserveLabel = "[x] Copy preserve mode"
 	}
+	directLabel := "[ ] Direct server-to-server"
+	if defs.DirectTransferOn {
+		directLabel = "[x] Direct server-to-server"
+	}
+	editorLabel := "[ ] External editor ($EDITOR)"
+	if defs.ExternalEditorOn {
+		editorLabel = "[x] External editor ($EDITOR)"
+	}
 	return []MenuItem{
 		{Label: preserveLabel, Key: "", Action: defs.OnTogglePreserve, HotKey: 'P'},
+		{Label: directLabel, Key: "", Action: defs.OnToggleDirect, HotKey: 'D'},
+		{Label: "Transfer rate limit...", Key: "", Action: defs.OnRateLimit, HotKey: 'L'},
+		{Label: editorLabel, Key: "", Action: defs.OnToggleEditor, HotKey: 'E'},
 	}
 }
 
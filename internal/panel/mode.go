@@ -5,4 +5,5 @@ type DisplayMode int
 const (
 	ModeFull DisplayMode = iota
 	ModeBrief
+	ModeQuickView // shows a preview of the other panel's current entry
 )
@@ -28,8 +28,12 @@ type Panel struct {
 	SearchBuf string
 	BriefRows int // rows used in last Brief mode render (for cursor navigation)
 
+	Preview  *Preview    // shown in quick view mode
+	listMode DisplayMode // mode to return to from quick view
+
 	FS              vfs.FileSystem
 	ConnectedServer string
+	Session         *vfs.Session // set while connected to a remote server
 }
 
 // NewPanel creates a new file panel at the given path.
@@ -121,6 +125,10 @@ func (p *Panel) Render() {
 		p.Table.SetSelectable(true, true) // cell-level selection
 		p.BriefRows = p.calcBriefRows()
 		RenderBrief(p.Table, p.Entries, p.Cursor, p.Selection, p.Active, p.BriefRows)
+	case ModeQuickView:
+		p.Table.SetFixed(0, 0)
+		p.Table.SetSelectable(false, false)
+		RenderQuickView(p.Table, p.Preview)
 	default:
 		p.Table.SetFixed(1, 0)
 		p.Table.SetSelectable(true, false) // row-level selection
@@ -132,17 +140,31 @@ func (p *Panel) Render() {
 func (p *Panel) UpdateTitle() {
 	title := p.Path
 	if p.ConnectedServer != "" {
-		title = "[" + p.ConnectedServer + "] " + p.Path
+		server := p.ConnectedServer
+		switch vfs.StateOf(p.FS) {
+		case vfs.StateReconnecting:
+			server += " ↻" // reconnecting
+		case vfs.StateClosed:
+			server += " ✗" // connection lost
+		}
+		title = "[" + server + "] " + p.Path
 	} else {
 		title = shortenPath(p.Path)
 	}
+	if p.Mode == ModeQuickView {
+		title = p.quickViewTitle()
+	}
 	p.Box.SetTitle(" " + title + " ")
 	p.Box.SetTitleAlign(tview.AlignLeft)
 	p.Box.SetTitleColor(theme.ColorHeaderFg)
 
 	p.Box.Clear()
 	e := p.CurrentEntry()
-	if e != nil && e.IsLink && e.LinkTo != "" {
+	if p.Mode == ModeQuickView {
+		if p.Preview != nil {
+			p.Box.AddText(p.Preview.Info, false, tview.AlignCenter, theme.ColorHeaderFg)
+		}
+	} else if e != nil && e.IsLink && e.LinkTo != "" {
 		p.Box.AddText("@ → "+e.LinkTo, false, tview.AlignCenter, theme.ColorSymlink)
 	} else if p.Mode == ModeBrief && e != nil && e.Name != ".." {
 		p.Box.AddText(FormatEntryInfo(e), false, tview.AlignCenter, theme.ColorHeaderFg)
@@ -349,6 +371,9 @@ func (p *Panel) calcBriefRows() int {
 
 // HandleSelectionChanged is called when the table selection changes.
 func (p *Panel) HandleSelectionChanged(row, col int) {
+	if p.Mode == ModeQuickView {
+		return
+	}
 	if p.Mode == ModeBrief {
 		h := p.BriefRows
 		if h <= 0 {
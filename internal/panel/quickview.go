@@ -0,0 +1,72 @@
+package panel
+
+import (
+	"github.com/rivo/tview"
+
+	"github.com/feherkaroly/vc/internal/theme"
+)
+
+// Preview is what a panel in quick view mode shows.
+type Preview struct {
+	Name  string   // the entry previewed, shown in the title
+	Lines []string // plain text, one table row each
+	Info  string   // footer line, e.g. size and date
+}
+
+// SetQuickView switches the panel to quick view mode, or back to the mode
+// it listed files in before. The entries and cursor are kept meanwhile.
+func (p *Panel) SetQuickView(on bool) {
+	if on == (p.Mode == ModeQuickView) {
+		return
+	}
+	if on {
+		p.listMode, p.Mode = p.Mode, ModeQuickView
+	} else {
+		p.Mode, p.Preview = p.listMode, nil
+	}
+	p.Render()
+	p.UpdateTitle()
+}
+
+// ListMode returns the mode the panel lists files in, the one quick view
+// returns to.
+func (p *Panel) ListMode() DisplayMode {
+	if p.Mode == ModeQuickView {
+		return p.listMode
+	}
+	return p.Mode
+}
+
+// ShowPreview sets what the panel shows in quick view mode.
+func (p *Panel) ShowPreview(pv *Preview) {
+	p.Preview = pv
+	if p.Mode == ModeQuickView {
+		p.Render()
+		p.UpdateTitle()
+	}
+}
+
+// RenderQuickView renders the panel table in quick view mode.
+func RenderQuickView(table *tview.Table, pv *Preview) {
+	table.Clear()
+	table.SetOffset(0, 0)
+	if pv == nil {
+		return
+	}
+	for i, line := range pv.Lines {
+		cell := tview.NewTableCell(tview.Escape(line)).
+			SetTextColor(theme.ColorNormalFile).
+			SetBackgroundColor(theme.ColorPanelBg).
+			SetSelectable(false).
+			SetExpansion(1)
+		table.SetCell(i, 0, cell)
+	}
+}
+
+// quickViewTitle returns the border title in quick view mode.
+func (p *Panel) quickViewTitle() string {
+	if p.Preview == nil || p.Preview.Name == "" {
+		return "Quick view"
+	}
+	return "Quick view: " + p.Preview.Name
+}
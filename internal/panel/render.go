@@ -178,14 +178,15 @@ func fileColor(entry model.FileEntry) tcell.Color {
 func formatSize(entry model.FileEntry) string {
 	if entry.IsDir {
 		if entry.DirSize >= 0 {
-			return formatNumber(entry.DirSize)
+			return FormatNumber(entry.DirSize)
 		}
 		return "<DIR>"
 	}
-	return formatNumber(entry.Size)
+	return FormatNumber(entry.Size)
 }
 
-func formatNumber(n int64) string {
+// FormatNumber formats n with thousands separators.
+func FormatNumber(n int64) string {
 	if n < 1000 {
 		return fmt.Sprintf("%d", n)
 	}
@@ -217,13 +218,14 @@ func FormatSummary(entries []model.FileEntry, sel *model.Selection) string {
 
 	if sel.Count() > 0 {
 		return fmt.Sprintf("%d selected, %s in %d/%d files",
-			sel.Count(), formatBytes(sel.TotalSize(entries)), fileCount, fileCount+dirCount)
+			sel.Count(), FormatBytes(sel.TotalSize(entries)), fileCount, fileCount+dirCount)
 	}
 
-	return fmt.Sprintf("%s in %d file(s)", formatBytes(totalSize), fileCount)
+	return fmt.Sprintf("%s in %d file(s)", FormatBytes(totalSize), fileCount)
 }
 
-func formatBytes(b int64) string {
+// FormatBytes formats a size in bytes, KB, MB or GB.
+func FormatBytes(b int64) string {
 	const (
 		KB = 1024
 		MB = KB * 1024
@@ -0,0 +1,331 @@
+// Package textenc describes the text encodings vc can display and convert,
+// and guesses which one a file uses.
+package textenc
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// Encoding is a text encoding. Single-byte code pages are decoded with a
+// charmap; UTF-16 uses two-byte code units.
+type Encoding struct {
+	Name      string
+	charmap   *charmap.Charmap
+	utf16     bool
+	bigEndian bool
+}
+
+var (
+	UTF8    = &Encoding{Name: "UTF-8"}
+	UTF16LE = &Encoding{Name: "UTF-16LE", utf16: true}
+	UTF16BE = &Encoding{Name: "UTF-16BE", utf16: true, bigEndian: true}
+)
+
+// All lists the supported encodings in the order they are cycled through.
+var All = []*Encoding{
+	UTF8,
+	{Name: "CP1250", charmap: charmap.Windows1250},
+	{Name: "ISO-8859-2", charmap: charmap.ISO8859_2},
+	{Name: "CP437", charmap: charmap.CodePage437},
+	{Name: "CP852", charmap: charmap.CodePage852},
+	{Name: "CP1252", charmap: charmap.Windows1252},
+	{Name: "ISO-8859-1", charmap: charmap.ISO8859_1},
+	{Name: "KOI8-R", charmap: charmap.KOI8R},
+	UTF16LE,
+	UTF16BE,
+}
+
+// ByName returns the encoding with the given name, ignoring case, or nil.
+func ByName(name string) *Encoding {
+	for _, e := range All {
+		if strings.EqualFold(e.Name, name) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Names returns the names of all encodings.
+func Names() []string {
+	names := make([]string, len(All))
+	for i, e := range All {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// Step returns the encoding after e in All, or before it with back.
+func (e *Encoding) Step(back bool) *Encoding {
+	for i, x := range All {
+		if x == e {
+			if back {
+				return All[(i+len(All)-1)%len(All)]
+			}
+			return All[(i+1)%len(All)]
+		}
+	}
+	return UTF8
+}
+
+// Unit returns the size of a code unit in bytes.
+func (e *Encoding) Unit() int {
+	if e.utf16 {
+		return 2
+	}
+	return 1
+}
+
+// NewDecoder returns a decoder converting the encoding to UTF-8. A UTF-16
+// byte order mark is consumed.
+func (e *Encoding) NewDecoder() *encoding.Decoder {
+	switch {
+	case e.charmap != nil:
+		return e.charmap.NewDecoder()
+	case e.utf16 && e.bigEndian:
+		return xunicode.UTF16(xunicode.BigEndian, xunicode.UseBOM).NewDecoder()
+	case e.utf16:
+		return xunicode.UTF16(xunicode.LittleEndian, xunicode.UseBOM).NewDecoder()
+	}
+	return encoding.Nop.NewDecoder()
+}
+
+// NewEncoder returns an encoder converting UTF-8 to the encoding. No byte
+// order mark is written.
+func (e *Encoding) NewEncoder() *encoding.Encoder {
+	switch {
+	case e.charmap != nil:
+		return e.charmap.NewEncoder()
+	case e.utf16 && e.bigEndian:
+		return xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM).NewEncoder()
+	case e.utf16:
+		return xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM).NewEncoder()
+	}
+	return encoding.Nop.NewEncoder()
+}
+
+// BOM returns the byte order mark b starts with, if it is one of e's.
+func (e *Encoding) BOM(b []byte) []byte {
+	var bom []byte
+	switch {
+	case e == UTF8:
+		bom = []byte{0xEF, 0xBB, 0xBF}
+	case e == UTF16LE:
+		bom = []byte{0xFF, 0xFE}
+	case e == UTF16BE:
+		bom = []byte{0xFE, 0xFF}
+	}
+	if bom != nil && bytes.HasPrefix(b, bom) {
+		return bom
+	}
+	return nil
+}
+
+// Detect guesses the encoding of a file from its first bytes: a byte order
+// mark decides; otherwise valid UTF-8 is assumed to be UTF-8, and for
+// anything else the code page whose decoding looks most like text wins.
+func Detect(head []byte) *Encoding {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return UTF8
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	}
+	if validUTF8(head) {
+		return UTF8
+	}
+
+	// ASCII text in UTF-16 has a zero byte in every other position.
+	var zeros [2]int
+	for i, b := range head {
+		if b == 0 {
+			zeros[i%2]++
+		}
+	}
+	if zeros[1] > len(head)/4 && zeros[0] == 0 {
+		return UTF16LE
+	}
+	if zeros[0] > len(head)/4 && zeros[1] == 0 {
+		return UTF16BE
+	}
+
+	best, bestScore := UTF8, 0
+	for _, e := range All {
+		if e.charmap == nil {
+			continue
+		}
+		if s := e.score(head); s > bestScore {
+			best, bestScore = e, s
+		}
+	}
+	return best
+}
+
+// validUTF8 is utf8.Valid allowing a sequence cut off at the end of b.
+func validUTF8(b []byte) bool {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-3; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				b = b[:i]
+			}
+			break
+		}
+	}
+	return utf8.Valid(b)
+}
+
+// score rates how much b decoded with a code page looks like text: letters
+// and box drawing count for it, control characters strongly against.
+func (e *Encoding) score(b []byte) int {
+	score := 0
+	for _, c := range b {
+		if c < 0x80 {
+			continue
+		}
+		r := e.charmap.DecodeByte(c)
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r):
+			score -= 5
+		case unicode.IsLetter(r), r >= 0x2500 && r <= 0x259F:
+			score += 2
+		case unicode.IsPunct(r), unicode.IsSpace(r):
+			score++
+		}
+	}
+	return score
+}
+
+// Decode converts b, which starts at file offset base, to UTF-8. Byte i of
+// text belongs to the character starting at b[offs[i]]; offs has a final
+// entry of len(b). For UTF-8, text is b itself and offs is nil, meaning
+// offsets are unchanged.
+func (e *Encoding) Decode(b []byte, base int64) (text []byte, offs []int) {
+	if e == UTF8 || e == nil {
+		return b, nil
+	}
+	text = make([]byte, 0, len(b)+len(b)/2)
+	offs = make([]int, 0, cap(text)+1)
+	add := func(r rune, at int) {
+		n := len(text)
+		text = utf8.AppendRune(text, r)
+		for ; n < len(text); n++ {
+			offs = append(offs, at)
+		}
+	}
+
+	if e.charmap != nil {
+		for i, c := range b {
+			add(e.charmap.DecodeByte(c), i)
+		}
+		return text, append(offs, len(b))
+	}
+
+	i := int(base % 2)
+	for i+1 < len(b) {
+		r := e.unit(b, i)
+		n := 2
+		if utf16.IsSurrogate(r) && i+3 < len(b) {
+			if pair := utf16.DecodeRune(r, e.unit(b, i+2)); pair != utf8.RuneError {
+				r, n = pair, 4
+			}
+		}
+		if utf16.IsSurrogate(r) {
+			r = utf8.RuneError
+		}
+		add(r, i)
+		i += n
+	}
+	return text, append(offs, len(b))
+}
+
+func (e *Encoding) unit(b []byte, i int) rune {
+	if e.bigEndian {
+		return rune(b[i])<<8 | rune(b[i+1])
+	}
+	return rune(b[i+1])<<8 | rune(b[i])
+}
+
+// Offset maps an offset in decoded text back to an offset in the bytes it
+// was decoded from.
+func Offset(offs []int, i int) int {
+	if offs == nil {
+		return i
+	}
+	return offs[i]
+}
+
+// isNewline reports whether b[i] is the '\n' of a newline code unit, b
+// starting at file offset base. It returns where the unit starts and ends.
+func (e *Encoding) isNewline(b []byte, i int, base int64) (int, int, bool) {
+	if !e.utf16 {
+		return i, i + 1, true
+	}
+	odd := (base+int64(i))%2 == 1
+	if e.bigEndian {
+		if odd && i > 0 && b[i-1] == 0 {
+			return i - 1, i + 1, true
+		}
+	} else if !odd && i+1 < len(b) && b[i+1] == 0 {
+		return i, i + 2, true
+	}
+	return 0, 0, false
+}
+
+// IndexNewline returns the start and end of the first newline in b, which
+// starts at file offset base, or -1, -1.
+func (e *Encoding) IndexNewline(b []byte, base int64) (int, int) {
+	for i := 0; i < len(b); {
+		j := bytes.IndexByte(b[i:], '\n')
+		if j < 0 {
+			break
+		}
+		if start, end, ok := e.isNewline(b, i+j, base); ok {
+			return start, end
+		}
+		i += j + 1
+	}
+	return -1, -1
+}
+
+// LastNewline returns the start and end of the last newline in b, which
+// starts at file offset base, or -1, -1.
+func (e *Encoding) LastNewline(b []byte, base int64) (int, int) {
+	for i := len(b); i > 0; {
+		j := bytes.LastIndexByte(b[:i], '\n')
+		if j < 0 {
+			break
+		}
+		if start, end, ok := e.isNewline(b, j, base); ok {
+			return start, end
+		}
+		i = j
+	}
+	return -1, -1
+}
+
+// CountNewlines returns the number of newlines in b, which starts at file
+// offset base.
+func (e *Encoding) CountNewlines(b []byte, base int64) int {
+	if !e.utf16 {
+		return bytes.Count(b, []byte{'\n'})
+	}
+	n := 0
+	for i := 0; i < len(b); {
+		_, end := e.IndexNewline(b[i:], base+int64(i))
+		if end < 0 {
+			break
+		}
+		n++
+		i += end
+	}
+	return n
+}
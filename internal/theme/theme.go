@@ -45,4 +45,13 @@ var (
 	// Inactive/Active panel border
 	ColorInactiveBorder = tcell.NewRGBColor(0, 170, 170)
 	ColorActiveBorder   = tcell.ColorWhite
+
+	// Syntax highlighting in the viewer
+	ColorSyntaxKeyword  = tcell.ColorWhite
+	ColorSyntaxType     = tcell.NewRGBColor(85, 255, 85)   // Bright green
+	ColorSyntaxFunction = tcell.NewRGBColor(85, 255, 255)  // Bright cyan
+	ColorSyntaxString   = tcell.NewRGBColor(255, 255, 85)  // Yellow
+	ColorSyntaxNumber   = tcell.NewRGBColor(255, 85, 255)  // Bright magenta
+	ColorSyntaxComment  = tcell.NewRGBColor(170, 170, 170) // Light gray
+	ColorSyntaxMarkup   = tcell.NewRGBColor(170, 170, 255) // Light blue-white
 )
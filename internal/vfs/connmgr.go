@@ -2,79 +2,231 @@ package vfs
 
 import (
 	"fmt"
+	"os"
 	"sync"
 
 	"github.com/feherkaroly/vc/internal/config"
 )
 
-// ConnMgr manages active remote filesystem connections.
+// ConnMgr manages active remote filesystem connections. Each server has at
+// most one connection, shared by reference-counted sessions.
 type ConnMgr struct {
 	mu    sync.Mutex
-	conns map[string]FileSystem // keyed by server name
+	conns map[string]*conn // keyed by server name
+
+	// OnStateChange is called (from a background goroutine) whenever a
+	// connection drops or is re-established.
+	OnStateChange func(name string, state ConnState)
+
+	// Limit is the global transfer rate limit shared by all connections.
+	Limit *Limiter
+}
+
+type conn struct {
+	fs       FileSystem // nil while dialing
+	refs     int
+	cacheDir string // local copies of remote files, removed with the connection
+
+	ready chan struct{} // closed when dialing is over
+	err   error         // why dialing failed
+}
+
+func (c *conn) close() {
+	c.fs.Close()
+	if c.cacheDir != "" {
+		os.RemoveAll(c.cacheDir)
+	}
+}
+
+// Session is one user's handle on a shared connection, typically held by a
+// panel. The connection is closed when its last session is released.
+type Session struct {
+	mgr  *ConnMgr
+	cfg  config.ServerConfig
+	fs   FileSystem
+	once sync.Once
+}
+
+// FS returns the session's filesystem.
+func (s *Session) FS() FileSystem {
+	return s.fs
+}
+
+// Name returns the name of the server the session is connected to.
+func (s *Session) Name() string {
+	return s.cfg.Name
+}
+
+// Config returns the server config the session was opened with.
+func (s *Session) Config() config.ServerConfig {
+	return s.cfg
+}
+
+// CacheDir returns a private local directory for copies of this server's
+// files. It is created on first use and deleted when the connection closes.
+func (s *Session) CacheDir() (string, error) {
+	return s.mgr.cacheDir(s.cfg.Name, s.fs)
+}
+
+// Share returns another session on the same connection, so it stays open
+// until both are released. s must not have been released yet.
+func (s *Session) Share() *Session {
+	s.mgr.mu.Lock()
+	defer s.mgr.mu.Unlock()
+	if c := s.mgr.conns[s.cfg.Name]; c != nil && c.fs == s.fs {
+		c.refs++
+	}
+	return &Session{mgr: s.mgr, cfg: s.cfg, fs: s.fs}
+}
+
+// Release gives up the session. Calling it more than once has no effect.
+func (s *Session) Release() {
+	s.once.Do(func() {
+		s.mgr.release(s.cfg.Name, s.fs)
+	})
 }
 
 // NewConnMgr creates a new connection manager.
 func NewConnMgr() *ConnMgr {
 	return &ConnMgr{
-		conns: make(map[string]FileSystem),
+		conns: make(map[string]*conn),
+		Limit: NewLimiter(0, nil),
 	}
 }
 
-// Connect returns an existing connection for the given server config, or creates a new one.
-func (cm *ConnMgr) Connect(cfg config.ServerConfig) (FileSystem, error) {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
+// Open returns a new session for the given server config, connecting first
+// if no other session is using the server. The dial happens outside the
+// lock, so a slow server doesn't hold up the other connections; opening the
+// same server meanwhile waits for that dial instead of starting another.
+func (cm *ConnMgr) Open(cfg config.ServerConfig) (*Session, error) {
+	for {
+		cm.mu.Lock()
+		c, ok := cm.conns[cfg.Name]
+		if !ok {
+			break
+		}
+		if c.fs != nil {
+			c.refs++
+			cm.mu.Unlock()
+			return &Session{mgr: cm, cfg: cfg, fs: c.fs}, nil
+		}
+		cm.mu.Unlock()
 
-	if fs, ok := cm.conns[cfg.Name]; ok {
-		return fs, nil
+		<-c.ready
+		if c.err != nil {
+			return nil, c.err
+		}
+		// Connected, unless the connection was closed again since; look
+		// it up once more.
 	}
 
-	var fs FileSystem
-	var err error
+	c := &conn{ready: make(chan struct{})}
+	cm.conns[cfg.Name] = c
+	cm.mu.Unlock()
 
-	switch cfg.Protocol {
-	case "sftp":
-		fs, err = NewSFTPFS(cfg)
-	case "ftp", "ftps":
-		fs, err = NewFTPFS(cfg)
-	default:
-		return nil, fmt.Errorf("unknown protocol: %s", cfg.Protocol)
+	name := cfg.Name
+	fs, err := newReconnectFS(cfg, dial, func(state ConnState) {
+		if cm.OnStateChange != nil {
+			cm.OnStateChange(name, state)
+		}
+	})
+	if err == nil {
+		fs.limit = NewLimiter(int64(cfg.RateLimit)*1024, cm.Limit)
 	}
 
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	defer close(c.ready)
+	if err == nil && cm.conns[name] != c {
+		// DisconnectAll ran while dialing.
+		fs.Close()
+		err = fmt.Errorf("connection to %s closed", name)
+	}
 	if err != nil {
+		c.err = err
+		if cm.conns[name] == c {
+			delete(cm.conns, name)
+		}
 		return nil, err
 	}
+	c.fs = fs
+	c.refs++
+	return &Session{mgr: cm, cfg: cfg, fs: fs}, nil
+}
+
+func (cm *ConnMgr) cacheDir(name string, fs FileSystem) (string, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 
-	cm.conns[cfg.Name] = fs
-	return fs, nil
+	c, ok := cm.conns[name]
+	if !ok || c.fs != fs {
+		return "", fmt.Errorf("not connected to %s", name)
+	}
+	if c.cacheDir == "" {
+		dir, err := os.MkdirTemp("", "vc-cache-")
+		if err != nil {
+			return "", err
+		}
+		c.cacheDir = dir
+	}
+	return c.cacheDir, nil
 }
 
-// Disconnect closes the connection for the given server name.
-func (cm *ConnMgr) Disconnect(name string) {
+// release drops a reference to the connection to name, unless fs belongs
+// to an earlier connection that DisconnectAll already closed.
+func (cm *ConnMgr) release(name string, fs FileSystem) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	if fs, ok := cm.conns[name]; ok {
-		fs.Close()
+	c, ok := cm.conns[name]
+	if !ok || c.fs != fs {
+		return
+	}
+	c.refs--
+	if c.refs <= 0 {
+		c.close()
 		delete(cm.conns, name)
 	}
 }
 
+// dial opens a new connection using the protocol named in cfg.
+func dial(cfg config.ServerConfig) (FileSystem, error) {
+	switch cfg.Protocol {
+	case "sftp":
+		return dialSSHFS(cfg)
+	case "ftp", "ftps", "ftps-implicit":
+		return NewFTPFS(cfg)
+	case "webdav", "webdavs":
+		return NewWebDAVFS(cfg)
+	case "s3", "s3-http":
+		return NewS3FS(cfg)
+	case "smb":
+		return NewSMBFS(cfg)
+	default:
+		return nil, fmt.Errorf("unknown protocol: %s", cfg.Protocol)
+	}
+}
+
 // IsConnected returns true if the given server name has an active connection.
+// A connection still being dialed doesn't count.
 func (cm *ConnMgr) IsConnected(name string) bool {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
-	_, ok := cm.conns[name]
-	return ok
+	c, ok := cm.conns[name]
+	return ok && c.fs != nil
 }
 
-// DisconnectAll closes all active connections. Call on application exit.
+// DisconnectAll closes all active connections, whether or not they still
+// have sessions, and removes their cached files. Call on application exit.
+// A dial in progress is closed when it finishes.
 func (cm *ConnMgr) DisconnectAll() {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	for name, fs := range cm.conns {
-		fs.Close()
+	for name, c := range cm.conns {
+		if c.fs != nil {
+			c.close()
+		}
 		delete(cm.conns, name)
 	}
 }
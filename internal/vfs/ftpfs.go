@@ -2,10 +2,14 @@ package vfs
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"net"
 	"os"
 	"path"
 	"strings"
@@ -16,18 +20,45 @@ import (
 	"github.com/jlaffaye/ftp"
 )
 
-// FTPFS implements FileSystem over an FTP/FTPS connection.
+// defaultFTPConnections is the pool size used when the server config doesn't set one.
+const defaultFTPConnections = 3
+
+// FTPFS implements FileSystem over a small pool of FTP/FTPS control
+// connections, so a long transfer doesn't block listings on the same server.
 type FTPFS struct {
-	conn   *ftp.ServerConn
-	mu     sync.Mutex
+	cfg    config.ServerConfig
+	dial   func() (*ftp.ServerConn, error)
+	idle   chan *ftp.ServerConn // connections ready for use
+	slots  chan struct{}        // one token per open connection
+	mu     sync.Mutex           // guards closed and pushes to idle
+	closed bool
 	done   chan struct{}
 }
 
+// CertError is returned when a server's TLS certificate cannot be verified.
+// Storing Fingerprint in ServerConfig.TLSFingerprint trusts that certificate.
+type CertError struct {
+	Host        string
+	Fingerprint string
+	Err         error
+}
+
+func (e *CertError) Error() string {
+	return fmt.Sprintf("certificate for %s is not trusted: %v", e.Host, e.Err)
+}
+
+func (e *CertError) Unwrap() error {
+	return e.Err
+}
+
 // NewFTPFS establishes an FTP/FTPS connection based on the given server config.
 func NewFTPFS(cfg config.ServerConfig) (*FTPFS, error) {
 	port := cfg.Port
 	if port == 0 {
 		port = 21
+		if cfg.Protocol == "ftps-implicit" {
+			port = 990
+		}
 	}
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, port)
@@ -35,26 +66,56 @@ func NewFTPFS(cfg config.ServerConfig) (*FTPFS, error) {
 	var opts []ftp.DialOption
 	opts = append(opts, ftp.DialWithTimeout(10*time.Second))
 
-	if cfg.Protocol == "ftps" {
-		opts = append(opts, ftp.DialWithExplicitTLS(&tls.Config{
-			InsecureSkipVerify: true,
-		}))
+	var certErr *CertError
+	tlsConfig := newTLSConfig(cfg, &certErr)
+
+	switch cfg.Protocol {
+	case "ftps":
+		opts = append(opts, ftp.DialWithExplicitTLS(tlsConfig))
+	case "ftps-implicit":
+		opts = append(opts, ftp.DialWithTLS(tlsConfig))
 	}
 
-	conn, err := ftp.Dial(addr, opts...)
-	if err != nil {
-		return nil, fmt.Errorf("FTP dial %s: %w", addr, err)
+	dial := func() (*ftp.ServerConn, error) {
+		conn, err := ftp.Dial(addr, opts...)
+		if err != nil {
+			if certErr != nil {
+				return nil, certErr
+			}
+			return nil, fmt.Errorf("FTP dial %s: %w", addr, err)
+		}
+		if err := conn.Login(cfg.User, cfg.Password); err != nil {
+			conn.Quit()
+			return nil, fmt.Errorf("FTP login: %w", err)
+		}
+		return conn, nil
+	}
+
+	size := cfg.MaxConnections
+	if size <= 0 {
+		size = defaultFTPConnections
+	} else if size < 2 {
+		// An open download holds its connection, so copying within the
+		// same server needs at least a second one for the upload.
+		size = 2
 	}
 
-	if err := conn.Login(cfg.User, cfg.Password); err != nil {
-		conn.Quit()
-		return nil, fmt.Errorf("FTP login: %w", err)
+	// Open the first connection up front so bad credentials or an untrusted
+	// certificate are reported immediately; the rest are dialled on demand.
+	conn, err := dial()
+	if err != nil {
+		return nil, err
 	}
 
 	f := &FTPFS{
-		conn: conn,
-		done: make(chan struct{}),
+		cfg:   cfg,
+		dial:  dial,
+		idle:  make(chan *ftp.ServerConn, size),
+		slots: make(chan struct{}, size),
+		done:  make(chan struct{}),
 	}
+	f.slots <- struct{}{}
+	f.idle <- conn
 
 	// Start NOOP keep-alive goroutine
 	go f.keepAlive()
@@ -62,15 +123,73 @@ func NewFTPFS(cfg config.ServerConfig) (*FTPFS, error) {
 	return f, nil
 }
 
+// acquire takes an idle connection from the pool, dialling a new one if the
+// pool isn't full yet, or waits until one is released.
+func (f *FTPFS) acquire() (*ftp.ServerConn, error) {
+	select {
+	case c := <-f.idle:
+		return c, nil
+	default:
+	}
+	select {
+	case c := <-f.idle:
+		return c, nil
+	case f.slots <- struct{}{}:
+		c, err := f.dial()
+		if err != nil {
+			<-f.slots
+			return nil, err
+		}
+		return c, nil
+	case <-f.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// release returns c to the pool. A connection that failed with a
+// connection error, or outlived the filesystem, is closed instead.
+func (f *FTPFS) release(c *ftp.ServerConn, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed || isConnError(err) {
+		c.Quit()
+		<-f.slots
+		return
+	}
+	f.idle <- c
+}
+
+// with runs op on a pooled connection.
+func (f *FTPFS) with(op func(c *ftp.ServerConn) error) error {
+	c, err := f.acquire()
+	if err != nil {
+		return err
+	}
+	err = op(c)
+	f.release(c, err)
+	return err
+}
+
 func (f *FTPFS) keepAlive() {
 	ticker := time.NewTicker(60 * time.Second)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			f.mu.Lock()
-			f.conn.NoOp()
-			f.mu.Unlock()
+			// Only idle connections need a NOOP; busy ones are in use.
+			var conns []*ftp.ServerConn
+		drain:
+			for {
+				select {
+				case c := <-f.idle:
+					conns = append(conns, c)
+				default:
+					break drain
+				}
+			}
+			for _, c := range conns {
+				f.release(c, c.NoOp())
+			}
 		case <-f.done:
 			return
 		}
@@ -78,9 +197,11 @@ func (f *FTPFS) keepAlive() {
 }
 
 func (f *FTPFS) ReadDir(dirPath string) ([]DirEntry, error) {
-	f.mu.Lock()
-	ftpEntries, err := f.conn.List(dirPath)
-	f.mu.Unlock()
+	var ftpEntries []*ftp.Entry
+	err := f.with(func(c *ftp.ServerConn) (err error) {
+		ftpEntries, err = c.List(dirPath)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -107,9 +228,11 @@ func (f *FTPFS) ReadDir(dirPath string) ([]DirEntry, error) {
 }
 
 func (f *FTPFS) Stat(filePath string) (FileInfo, error) {
-	f.mu.Lock()
-	entry, err := f.conn.GetEntry(filePath)
-	f.mu.Unlock()
+	var entry *ftp.Entry
+	err := f.with(func(c *ftp.ServerConn) (err error) {
+		entry, err = c.GetEntry(filePath)
+		return err
+	})
 	if err != nil {
 		return FileInfo{}, err
 	}
@@ -131,28 +254,78 @@ func (f *FTPFS) Readlink(_ string) (string, error) {
 }
 
 func (f *FTPFS) Open(filePath string) (io.ReadCloser, error) {
-	f.mu.Lock()
-	resp, err := f.conn.Retr(filePath)
-	f.mu.Unlock()
+	c, err := f.acquire()
 	if err != nil {
 		return nil, err
 	}
-	return resp, nil
+	resp, err := c.Retr(filePath)
+	if err != nil {
+		f.release(c, err)
+		return nil, err
+	}
+	return &ftpReadCloser{resp: resp, release: func(err error) { f.release(c, err) }}, nil
+}
+
+// OpenAt starts a download at offset using REST.
+func (f *FTPFS) OpenAt(filePath string, offset int64) (io.ReadCloser, error) {
+	c, err := f.acquire()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.RetrFrom(filePath, uint64(offset))
+	if err != nil {
+		f.release(c, err)
+		return nil, err
+	}
+	return &ftpReadCloser{resp: resp, release: func(err error) { f.release(c, err) }}, nil
+}
+
+// ftpReadCloser keeps a pooled connection busy until the download is closed.
+type ftpReadCloser struct {
+	resp    *ftp.Response
+	release func(error)
+	once    sync.Once
+}
+
+func (r *ftpReadCloser) Read(p []byte) (int, error) {
+	return r.resp.Read(p)
 }
 
-// ftpWriteCloser wraps an io.PipeWriter and waits for the background Stor goroutine to finish.
-type ftpWriteCloser struct {
+func (r *ftpReadCloser) Close() error {
+	err := r.resp.Close()
+	r.once.Do(func() { r.release(err) })
+	return err
+}
+
+// pipeWriteCloser wraps an io.PipeWriter and waits for the background upload goroutine to finish.
+// Close may be called more than once; later calls return the first result.
+type pipeWriteCloser struct {
 	pw   *io.PipeWriter
 	done chan error
+	once sync.Once
+	err  error
 }
 
-func (w *ftpWriteCloser) Write(p []byte) (int, error) {
+func (w *pipeWriteCloser) Write(p []byte) (int, error) {
 	return w.pw.Write(p)
 }
 
-func (w *ftpWriteCloser) Close() error {
-	w.pw.Close()
-	return <-w.done
+func (w *pipeWriteCloser) Close() error {
+	w.once.Do(func() {
+		w.pw.Close()
+		w.err = <-w.done
+	})
+	return w.err
+}
+
+// Abort fails the upload with err; the background goroutine sees it as a
+// read error and gives up the transfer.
+func (w *pipeWriteCloser) Abort(err error) error {
+	w.once.Do(func() {
+		w.pw.CloseWithError(err)
+		w.err = <-w.done
+	})
+	return w.err
 }
 
 func (f *FTPFS) Create(filePath string, _ fs.FileMode) (io.WriteCloser, error) {
@@ -160,14 +333,14 @@ func (f *FTPFS) Create(filePath string, _ fs.FileMode) (io.WriteCloser, error) {
 	done := make(chan error, 1)
 
 	go func() {
-		f.mu.Lock()
-		err := f.conn.Stor(filePath, pr)
-		f.mu.Unlock()
+		err := f.with(func(c *ftp.ServerConn) error {
+			return c.Stor(filePath, pr)
+		})
 		pr.CloseWithError(err)
 		done <- err
 	}()
 
-	return &ftpWriteCloser{pw: pw, done: done}, nil
+	return &pipeWriteCloser{pw: pw, done: done}, nil
 }
 
 func (f *FTPFS) MkdirAll(dirPath string, _ fs.FileMode) error {
@@ -179,9 +352,9 @@ func (f *FTPFS) MkdirAll(dirPath string, _ fs.FileMode) error {
 			continue
 		}
 		current = path.Join(current, part)
-		f.mu.Lock()
-		err := f.conn.MakeDir(current)
-		f.mu.Unlock()
+		err := f.with(func(c *ftp.ServerConn) error {
+			return c.MakeDir(current)
+		})
 		if err != nil {
 			// Ignore "already exists" errors
 			if !strings.Contains(err.Error(), "exists") &&
@@ -194,10 +367,9 @@ func (f *FTPFS) MkdirAll(dirPath string, _ fs.FileMode) error {
 }
 
 func (f *FTPFS) Remove(filePath string) error {
-	f.mu.Lock()
-	err := f.conn.Delete(filePath)
-	f.mu.Unlock()
-	return err
+	return f.with(func(c *ftp.ServerConn) error {
+		return c.Delete(filePath)
+	})
 }
 
 func (f *FTPFS) RemoveAll(filePath string) error {
@@ -207,10 +379,7 @@ func (f *FTPFS) RemoveAll(filePath string) error {
 	}
 
 	if !fi.IsDir {
-		f.mu.Lock()
-		err := f.conn.Delete(filePath)
-		f.mu.Unlock()
-		return err
+		return f.Remove(filePath)
 	}
 
 	entries, err := f.ReadDir(filePath)
@@ -225,29 +394,23 @@ func (f *FTPFS) RemoveAll(filePath string) error {
 		}
 	}
 
-	f.mu.Lock()
-	err = f.conn.RemoveDirRecur(filePath)
-	f.mu.Unlock()
-	if err != nil {
-		// Fallback: try simple RemoveDir
-		f.mu.Lock()
-		err = f.conn.RemoveDir(filePath)
-		f.mu.Unlock()
-	}
-	return err
+	return f.with(func(c *ftp.ServerConn) error {
+		if err := c.RemoveDirRecur(filePath); err != nil {
+			// Fallback: try simple RemoveDir
+			return c.RemoveDir(filePath)
+		}
+		return nil
+	})
 }
 
 func (f *FTPFS) Rename(oldpath, newpath string) error {
-	f.mu.Lock()
-	err := f.conn.Rename(oldpath, newpath)
-	f.mu.Unlock()
-	return err
+	return f.with(func(c *ftp.ServerConn) error {
+		return c.Rename(oldpath, newpath)
+	})
 }
 
 func (f *FTPFS) ReadFile(filePath string) ([]byte, error) {
-	f.mu.Lock()
-	resp, err := f.conn.Retr(filePath)
-	f.mu.Unlock()
+	resp, err := f.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -329,11 +492,97 @@ func (f *FTPFS) IsLocal() bool {
 	return false
 }
 
+// Ping checks that the server still accepts control connections.
+func (f *FTPFS) Ping() error {
+	return f.with(func(c *ftp.ServerConn) error {
+		return c.NoOp()
+	})
+}
+
+// Close shuts down idle connections; busy ones are closed when released.
 func (f *FTPFS) Close() error {
-	close(f.done)
 	f.mu.Lock()
-	defer f.mu.Unlock()
-	return f.conn.Quit()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	close(f.done)
+	f.mu.Unlock()
+
+	var firstErr error
+	for {
+		select {
+		case c := <-f.idle:
+			if err := c.Quit(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			<-f.slots
+		default:
+			return firstErr
+		}
+	}
+}
+
+// newTLSConfig returns a client TLS config that verifies the server with
+// verifyServerCert. A verification failure is also stored in *certErr, since
+// some clients don't wrap the handshake error.
+func newTLSConfig(cfg config.ServerConfig, certErr **CertError) *tls.Config {
+	return &tls.Config{
+		ServerName: cfg.Host,
+		// Verification happens in VerifyConnection so that a pinned
+		// fingerprint can stand in for the usual chain check.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			err := verifyServerCert(cfg, cs)
+			errors.As(err, certErr)
+			return err
+		},
+	}
+}
+
+// verifyServerCert checks the server certificate against the pinned
+// fingerprint if one is configured, otherwise against the system roots.
+func verifyServerCert(cfg config.ServerConfig, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("server sent no certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+	fp := CertFingerprint(leaf)
+
+	if cfg.TLSFingerprint != "" {
+		if normalizeFingerprint(cfg.TLSFingerprint) == normalizeFingerprint(fp) {
+			return nil
+		}
+		return &CertError{Host: cfg.Host, Fingerprint: fp, Err: errors.New("fingerprint does not match the pinned one")}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       cfg.Host,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return &CertError{Host: cfg.Host, Fingerprint: fp, Err: err}
+	}
+	return nil
+}
+
+// CertFingerprint returns the SHA-256 fingerprint of a certificate as colon-separated hex.
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+func normalizeFingerprint(fp string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(fp), ":", ""))
 }
 
 // ftpEntryMode converts an FTP entry to an approximate os.FileMode.
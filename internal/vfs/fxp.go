@@ -0,0 +1,203 @@
+package vfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fxpConn is a bare FTP control connection. FXP needs PORT pointed at
+// another server, which the regular client library doesn't allow.
+type fxpConn struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+func dialFXP(f *FTPFS) (*fxpConn, error) {
+	port := f.cfg.Port
+	if port == 0 {
+		port = 21
+	}
+	addr := net.JoinHostPort(f.cfg.Host, strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("FTP dial %s: %w", addr, err)
+	}
+
+	c := &fxpConn{conn: conn, text: textproto.NewConn(conn)}
+	if _, _, err := c.text.ReadResponse(2); err != nil {
+		c.close()
+		return nil, err
+	}
+	code, _, err := c.cmd(0, "USER %s", f.cfg.User)
+	if err == nil && code == 331 {
+		_, _, err = c.cmd(2, "PASS %s", f.cfg.Password)
+	} else if err == nil && code/100 != 2 {
+		err = fmt.Errorf("USER: unexpected reply %d", code)
+	}
+	if err == nil {
+		_, _, err = c.cmd(2, "TYPE I")
+	}
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("FTP login: %w", err)
+	}
+	return c, nil
+}
+
+// cmd sends a command and reads the reply, which must start with expect
+// (1 for preliminary, 2 for completion). With expect 0 any reply is returned.
+func (c *fxpConn) cmd(expect int, format string, args ...any) (int, string, error) {
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return 0, "", err
+	}
+	return c.text.ReadResponse(expect)
+}
+
+// pasv puts the server in passive mode and returns the address for PORT.
+func (c *fxpConn) pasv() (string, error) {
+	_, msg, err := c.cmd(2, "PASV")
+	if err != nil {
+		return "", err
+	}
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return "", fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+
+	// A server behind NAT often announces its private address; the other
+	// server has to use the public one we are connected to.
+	ip := net.ParseIP(strings.Join(fields[:4], "."))
+	if remote, ok := c.conn.RemoteAddr().(*net.TCPAddr); ok && ip != nil {
+		if (ip.IsPrivate() || ip.IsUnspecified()) && !remote.IP.IsPrivate() {
+			if v4 := remote.IP.To4(); v4 != nil {
+				fields[0], fields[1], fields[2], fields[3] =
+					strconv.Itoa(int(v4[0])), strconv.Itoa(int(v4[1])), strconv.Itoa(int(v4[2])), strconv.Itoa(int(v4[3]))
+			}
+		}
+	}
+	return strings.Join(fields, ","), nil
+}
+
+// noDirect turns a reply saying the data connection between the servers
+// couldn't be opened (425) or broke (426), typically because of a firewall,
+// into ErrNoDirectTransfer, so the copy is relayed.
+func noDirect(err error) error {
+	var te *textproto.Error
+	if errors.As(err, &te) && (te.Code == 425 || te.Code == 426) {
+		return fmt.Errorf("%w: %v", ErrNoDirectTransfer, err)
+	}
+	return err
+}
+
+func (c *fxpConn) close() {
+	c.text.PrintfLine("QUIT")
+	c.conn.Close()
+}
+
+// fxpFile sends one file from src to dst: dst listens (PASV), src connects
+// to it (PORT) and the two servers exchange the data between themselves.
+func fxpFile(src, dst *fxpConn, srcPath, dstPath string) error {
+	addr, err := dst.pasv()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoDirectTransfer, err)
+	}
+	if _, _, err := src.cmd(2, "PORT %s", addr); err != nil {
+		return fmt.Errorf("%w: %v", ErrNoDirectTransfer, err)
+	}
+	if _, _, err := dst.cmd(1, "STOR %s", dstPath); err != nil {
+		return noDirect(err)
+	}
+	if _, _, err := src.cmd(1, "RETR %s", srcPath); err != nil {
+		// dst is still waiting for a connection; drop the transfer.
+		dst.cmd(0, "ABOR")
+		return noDirect(err)
+	}
+	_, _, srcErr := src.text.ReadResponse(2)
+	_, _, dstErr := dst.text.ReadResponse(2)
+	if srcErr != nil {
+		return noDirect(srcErr)
+	}
+	return noDirect(dstErr)
+}
+
+// TransferTo sends src straight to dst with FXP when dst is another plain
+// FTP server. Many servers refuse PORT to a foreign address or sit behind
+// firewalls that block the data connection, and some don't allow the extra
+// logins; that is reported as ErrNoDirectTransfer so the copy can be
+// relayed instead.
+func (f *FTPFS) TransferTo(ctx context.Context, src string, dst FileSystem, dstPath string, _ bool) error {
+	to, ok := dst.(*FTPFS)
+	if !ok || to == f || f.cfg.Protocol != "ftp" || to.cfg.Protocol != "ftp" {
+		return ErrNoDirectTransfer
+	}
+
+	info, err := f.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := to.MkdirAll(path.Dir(dstPath), 0755); err != nil {
+		return err
+	}
+
+	srcConn, err := dialFXP(f)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoDirectTransfer, err)
+	}
+	defer srcConn.close()
+	dstConn, err := dialFXP(to)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoDirectTransfer, err)
+	}
+	defer dstConn.close()
+
+	stop := context.AfterFunc(ctx, func() {
+		srcConn.conn.Close()
+		dstConn.conn.Close()
+	})
+	defer stop()
+
+	var send func(s, d string, isDir bool) error
+	send = func(s, d string, isDir bool) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !isDir {
+			return fxpFile(srcConn, dstConn, s, d)
+		}
+		if err := to.MkdirAll(d, 0755); err != nil {
+			return err
+		}
+		entries, err := f.ReadDir(s)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Name == "." || e.Name == ".." {
+				continue
+			}
+			if err := send(path.Join(s, e.Name), path.Join(d, e.Name), e.IsDir); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := send(src, dstPath, info.IsDir); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// RandomAccessFile is a file opened for reading at arbitrary offsets.
+type RandomAccessFile interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// rangeOpener is implemented by filesystems that can start a download at an
+// offset without being able to seek within it.
+type rangeOpener interface {
+	OpenAt(path string, offset int64) (io.ReadCloser, error)
+}
+
+// OpenRandom opens path for reading at arbitrary offsets. Local, SFTP, S3 and
+// SMB files support this directly. FTP, WebDAV and shell-only SSH files are
+// streamed, and the stream is restarted at the offset whenever a read is not
+// sequential. Filesystems that can do neither are refused: reaching an
+// offset would mean downloading everything before it.
+func OpenRandom(fsys FileSystem, path string) (RandomAccessFile, error) {
+	rc, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if ra, ok := rc.(RandomAccessFile); ok {
+		return ra, nil
+	}
+	ro, ok := Unwrap(fsys).(rangeOpener)
+	if !ok {
+		rc.Close()
+		return nil, errors.ErrUnsupported
+	}
+	open := func(offset int64) (io.ReadCloser, error) {
+		return ro.OpenAt(path, offset)
+	}
+	return &streamFile{open: open, rc: rc}, nil
+}
+
+// Streamed reports whether f is read through a sequential download, so
+// reading all of it means downloading all of it.
+func Streamed(f RandomAccessFile) bool {
+	_, ok := f.(*streamFile)
+	return ok
+}
+
+// streamFile adapts a sequential download to io.ReaderAt.
+type streamFile struct {
+	mu   sync.Mutex
+	open func(offset int64) (io.ReadCloser, error)
+	rc   io.ReadCloser
+	pos  int64
+}
+
+func (s *streamFile) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Short skips are cheaper to read through than to reconnect for.
+	if s.rc == nil || off < s.pos || off-s.pos > 1<<20 {
+		if s.rc != nil {
+			s.rc.Close()
+			s.rc = nil
+		}
+		rc, err := s.open(off)
+		if err != nil {
+			return 0, err
+		}
+		s.rc, s.pos = rc, off
+	}
+	if off > s.pos {
+		n, err := io.CopyN(io.Discard, s.rc, off-s.pos)
+		s.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := io.ReadFull(s.rc, p)
+	s.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	// A finished stream does not see data appended later, so the next read
+	// starts a new one.
+	if err == io.EOF {
+		s.rc.Close()
+		s.rc = nil
+	}
+	return n, err
+}
+
+func (s *streamFile) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rc == nil {
+		return nil
+	}
+	err := s.rc.Close()
+	s.rc = nil
+	return err
+}
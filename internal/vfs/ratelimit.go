@@ -0,0 +1,133 @@
+package vfs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket limiting transfer speed in bytes per second.
+// A server's limiter has the global limiter as its parent, so a transfer
+// is held to whichever of the two is lower. The zero rate means unlimited.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+	parent *Limiter
+}
+
+// NewLimiter creates a limiter allowing rate bytes per second, 0 for no limit.
+func NewLimiter(rate int64, parent *Limiter) *Limiter {
+	return &Limiter{rate: rate, parent: parent}
+}
+
+// Rate returns the current limit in bytes per second.
+func (l *Limiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// SetRate changes the limit; transfers in progress pick it up immediately.
+func (l *Limiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.refill()
+}
+
+// refill adds the tokens earned since the last call, allowing bursts of up
+// to one second's worth. Must be called with mu held.
+func (l *Limiter) refill() {
+	now := time.Now()
+	if l.rate <= 0 {
+		l.tokens = 0
+	} else if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+		if l.tokens > float64(l.rate) {
+			l.tokens = float64(l.rate)
+		}
+	}
+	l.last = now
+}
+
+// wait takes n tokens and sleeps until the bucket is out of debt. The sleep
+// is done in short steps so a changed rate or a cancelled ctx takes effect
+// quickly even when n is large compared to the rate.
+func (l *Limiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	l.refill()
+	if l.rate > 0 {
+		l.tokens -= float64(n)
+	}
+	l.mu.Unlock()
+
+	for {
+		l.mu.Lock()
+		l.refill()
+		rate, debt := l.rate, -l.tokens
+		l.mu.Unlock()
+
+		if rate <= 0 || debt <= 0 {
+			return nil
+		}
+		d := time.Duration(debt / float64(rate) * float64(time.Second))
+		if d > 100*time.Millisecond {
+			d = 100 * time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// Wait blocks until n bytes may pass all the given limiters and their
+// parents. Each limiter is only charged once, so the global limit applies
+// once when both ends of a copy are remote. Nil limiters are ignored.
+func Wait(ctx context.Context, n int, limiters ...*Limiter) error {
+	seen := make(map[*Limiter]bool, 2*len(limiters))
+	for _, l := range limiters {
+		for ; l != nil; l = l.parent {
+			if seen[l] {
+				continue
+			}
+			seen[l] = true
+			if err := l.wait(ctx, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ChunkSize returns how many bytes to move before the next Wait: a tenth of
+// a second's worth at the lowest rate of the limiters and their parents, at
+// least 4 KB and at most limit. Reading that much at a time keeps a slow
+// transfer moving smoothly and lets a changed rate take effect quickly.
+func ChunkSize(limit int, limiters ...*Limiter) int {
+	size := int64(limit)
+	for _, l := range limiters {
+		for ; l != nil; l = l.parent {
+			if rate := l.Rate(); rate > 0 {
+				size = min(size, rate/10)
+			}
+		}
+	}
+	return int(min(int64(limit), max(size, 4096)))
+}
+
+// throttled is implemented by filesystems with a transfer rate limit.
+type throttled interface {
+	Limiter() *Limiter
+}
+
+// LimiterOf returns the rate limiter of a remote filesystem, or nil.
+func LimiterOf(fsys FileSystem) *Limiter {
+	if t, ok := fsys.(throttled); ok {
+		return t.Limiter()
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/feherkaroly/vc/internal/config"
+	"github.com/pkg/sftp"
+)
+
+// ConnState describes the health of a remote connection.
+type ConnState int
+
+const (
+	StateConnected ConnState = iota
+	StateReconnecting
+	StateClosed
+)
+
+const (
+	healthInterval   = 30 * time.Second
+	reconnectBackoff = 1 * time.Second
+	reconnectMaxWait = 60 * time.Second
+)
+
+// pinger is implemented by remote filesystems that support a cheap liveness check.
+type pinger interface {
+	Ping() error
+}
+
+// dialFunc opens a new connection for the given server config.
+type dialFunc func(cfg config.ServerConfig) (FileSystem, error)
+
+// reconnectFS wraps a remote FileSystem and transparently re-establishes the
+// session when it drops. Each operation is retried once after a reconnect.
+type reconnectFS struct {
+	cfg  config.ServerConfig
+	dial dialFunc
+
+	mu    sync.Mutex
+	fs    FileSystem
+	gen   int  // incremented on every successful reconnect
+	stale bool // fs has been closed and awaits replacement
+	state ConnState
+
+	redial sync.Mutex // serialises reconnect attempts
+
+	onState func(ConnState)
+	done    chan struct{}
+
+	limit *Limiter // per-server transfer rate limit
+}
+
+func newReconnectFS(cfg config.ServerConfig, dial dialFunc, onState func(ConnState)) (*reconnectFS, error) {
+	inner, err := dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r := &reconnectFS{
+		cfg:     cfg,
+		dial:    dial,
+		fs:      inner,
+		state:   StateConnected,
+		onState: onState,
+		done:    make(chan struct{}),
+	}
+	go r.monitor()
+	return r, nil
+}
+
+// Limiter returns the server's transfer rate limiter.
+func (r *reconnectFS) Limiter() *Limiter {
+	return r.limit
+}
+
+// State returns the current connection state.
+func (r *reconnectFS) State() ConnState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// Unwrap returns the currently active underlying filesystem.
+func (r *reconnectFS) Unwrap() FileSystem {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fs
+}
+
+func (r *reconnectFS) current() (FileSystem, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fs, r.gen
+}
+
+func (r *reconnectFS) setState(s ConnState) {
+	r.mu.Lock()
+	changed := r.state != s
+	r.state = s
+	r.mu.Unlock()
+	if changed && r.onState != nil {
+		r.onState(s)
+	}
+}
+
+// reconnect replaces the underlying filesystem unless another caller already
+// did so since generation gen was observed.
+func (r *reconnectFS) reconnect(gen int) error {
+	r.redial.Lock()
+	defer r.redial.Unlock()
+
+	r.mu.Lock()
+	if r.state == StateClosed {
+		r.mu.Unlock()
+		return net.ErrClosed
+	}
+	if r.gen != gen {
+		r.mu.Unlock()
+		return nil
+	}
+	old, stale := r.fs, r.stale
+	r.stale = true
+	r.mu.Unlock()
+
+	if !stale {
+		old.Close()
+	}
+	r.setState(StateReconnecting)
+
+	inner, err := r.dial(r.cfg)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	if r.state == StateClosed {
+		r.mu.Unlock()
+		inner.Close()
+		return net.ErrClosed
+	}
+	r.fs = inner
+	r.gen++
+	r.stale = false
+	r.mu.Unlock()
+
+	r.setState(StateConnected)
+	return nil
+}
+
+// monitor periodically checks the connection and keeps retrying with
+// exponential backoff while it is down.
+func (r *reconnectFS) monitor() {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+
+		fs, gen := r.current()
+		if r.State() == StateConnected {
+			if err := ping(fs); err == nil {
+				continue
+			}
+		}
+
+		wait := reconnectBackoff
+		for r.reconnect(gen) != nil {
+			select {
+			case <-r.done:
+				return
+			case <-time.After(wait):
+			}
+			wait *= 2
+			if wait > reconnectMaxWait {
+				wait = reconnectMaxWait
+			}
+			_, gen = r.current()
+		}
+	}
+}
+
+func ping(fs FileSystem) error {
+	if p, ok := fs.(pinger); ok {
+		return p.Ping()
+	}
+	_, err := fs.Stat("/")
+	return err
+}
+
+// retry runs op against the current filesystem and, on a connection error,
+// reconnects and runs it once more.
+func (r *reconnectFS) retry(op func(fs FileSystem) error) error {
+	fs, gen := r.current()
+	err := op(fs)
+	if !isConnError(err) {
+		return err
+	}
+	if rerr := r.reconnect(gen); rerr != nil {
+		return err
+	}
+	fs, _ = r.current()
+	return op(fs)
+}
+
+func (r *reconnectFS) ReadDir(path string) (entries []DirEntry, err error) {
+	err = r.retry(func(fs FileSystem) error {
+		entries, err = fs.ReadDir(path)
+		return err
+	})
+	return entries, err
+}
+
+func (r *reconnectFS) Stat(path string) (fi FileInfo, err error) {
+	err = r.retry(func(fs FileSystem) error {
+		fi, err = fs.Stat(path)
+		return err
+	})
+	return fi, err
+}
+
+func (r *reconnectFS) Lstat(path string) (fi FileInfo, err error) {
+	err = r.retry(func(fs FileSystem) error {
+		fi, err = fs.Lstat(path)
+		return err
+	})
+	return fi, err
+}
+
+func (r *reconnectFS) Readlink(path string) (target string, err error) {
+	err = r.retry(func(fs FileSystem) error {
+		target, err = fs.Readlink(path)
+		return err
+	})
+	return target, err
+}
+
+func (r *reconnectFS) Open(path string) (rc io.ReadCloser, err error) {
+	err = r.retry(func(fs FileSystem) error {
+		rc, err = fs.Open(path)
+		return err
+	})
+	return rc, err
+}
+
+func (r *reconnectFS) Create(path string, mode fs.FileMode) (wc io.WriteCloser, err error) {
+	err = r.retry(func(fs FileSystem) error {
+		wc, err = fs.Create(path, mode)
+		return err
+	})
+	return wc, err
+}
+
+func (r *reconnectFS) MkdirAll(path string, perm fs.FileMode) error {
+	return r.retry(func(fs FileSystem) error {
+		return fs.MkdirAll(path, perm)
+	})
+}
+
+func (r *reconnectFS) Remove(path string) error {
+	return r.retry(func(fs FileSystem) error {
+		return fs.Remove(path)
+	})
+}
+
+func (r *reconnectFS) RemoveAll(path string) error {
+	return r.retry(func(fs FileSystem) error {
+		return fs.RemoveAll(path)
+	})
+}
+
+func (r *reconnectFS) Rename(oldpath, newpath string) error {
+	return r.retry(func(fs FileSystem) error {
+		return fs.Rename(oldpath, newpath)
+	})
+}
+
+func (r *reconnectFS) ReadFile(path string) (data []byte, err error) {
+	err = r.retry(func(fs FileSystem) error {
+		data, err = fs.ReadFile(path)
+		return err
+	})
+	return data, err
+}
+
+func (r *reconnectFS) Walk(root string, fn WalkFunc) error {
+	fs, _ := r.current()
+	return fs.Walk(root, fn)
+}
+
+func (r *reconnectFS) Chmod(path string, mode os.FileMode) error {
+	return r.retry(func(fs FileSystem) error {
+		return fs.Chmod(path, mode)
+	})
+}
+
+func (r *reconnectFS) Chown(path string, uid, gid int) error {
+	return r.retry(func(fs FileSystem) error {
+		return fs.Chown(path, uid, gid)
+	})
+}
+
+func (r *reconnectFS) Join(elem ...string) string {
+	fs, _ := r.current()
+	return fs.Join(elem...)
+}
+
+func (r *reconnectFS) Dir(path string) string {
+	fs, _ := r.current()
+	return fs.Dir(path)
+}
+
+func (r *reconnectFS) Base(path string) string {
+	fs, _ := r.current()
+	return fs.Base(path)
+}
+
+func (r *reconnectFS) IsLocal() bool {
+	return false
+}
+
+func (r *reconnectFS) Close() error {
+	r.mu.Lock()
+	if r.state == StateClosed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.state = StateClosed
+	fs, stale := r.fs, r.stale
+	r.mu.Unlock()
+
+	close(r.done)
+	if stale {
+		return nil
+	}
+	return fs.Close()
+}
+
+// Unwrap returns the innermost filesystem behind any wrappers (such as the
+// automatic reconnect layer), for optional interface checks.
+func Unwrap(fs FileSystem) FileSystem {
+	for {
+		u, ok := fs.(interface{ Unwrap() FileSystem })
+		if !ok {
+			return fs
+		}
+		fs = u.Unwrap()
+	}
+}
+
+// StateOf returns the connection state of fs. Local filesystems are always connected.
+func StateOf(fs FileSystem) ConnState {
+	if s, ok := fs.(interface{ State() ConnState }); ok {
+		return s.State()
+	}
+	return StateConnected
+}
+
+// isConnError reports whether err indicates that the underlying session is gone.
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, sftp.ErrSSHFxConnectionLost) ||
+		errors.Is(err, sftp.ErrSSHFxNoConnection) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "connection lost") ||
+		strings.Contains(msg, "use of closed network connection") ||
+		strings.Contains(msg, "broken pipe") ||
+		strings.Contains(msg, "connection reset")
+}
@@ -0,0 +1,463 @@
+package vfs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/feherkaroly/vc/internal/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the multipart chunk size used for uploads of unknown length.
+const s3PartSize = 16 << 20
+
+// S3FS implements FileSystem over S3-compatible object storage. Buckets
+// appear as top-level directories and "/"-delimited key prefixes as
+// subdirectories; empty directories are kept as zero-byte "prefix/" markers.
+type S3FS struct {
+	client *minio.Client
+	region string
+}
+
+// NewS3FS connects to an S3 endpoint. The server's Host (and Port) name the
+// endpoint, User and Password hold the access key and secret key.
+func NewS3FS(cfg config.ServerConfig) (*S3FS, error) {
+	endpoint := cfg.Host
+	if cfg.Port != 0 {
+		endpoint = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	}
+	secure := cfg.Protocol != "s3-http"
+
+	// minio-go retries failed handshakes; cancelling the first request on a
+	// certificate error reports it right away instead.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var certErr *CertError
+	tlsConfig := newTLSConfig(cfg, &certErr)
+	verify := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		err := verify(cs)
+		if err != nil {
+			cancel()
+		}
+		return err
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.User, cfg.Password, ""),
+		Secure: secure,
+		Region: cfg.Region,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("S3 endpoint %s: %w", endpoint, err)
+	}
+
+	s := &S3FS{client: client, region: cfg.Region}
+
+	// Check the credentials. Accounts that may not list buckets are still
+	// usable by navigating to a bucket path directly.
+	if _, err := client.ListBuckets(ctx); err != nil {
+		if certErr != nil {
+			return nil, certErr
+		}
+		if minio.ToErrorResponse(err).Code != "AccessDenied" {
+			return nil, fmt.Errorf("S3 connect %s: %w", endpoint, err)
+		}
+	}
+
+	return s, nil
+}
+
+// split turns an absolute path into a bucket name and object key.
+func (s *S3FS) split(p string) (bucket, key string) {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	bucket, key, _ = strings.Cut(p, "/")
+	return bucket, key
+}
+
+func s3NotFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NoSuchBucket" || code == "NotFound"
+}
+
+func (s *S3FS) ReadDir(dirPath string) ([]DirEntry, error) {
+	ctx := context.Background()
+	bucket, key := s.split(dirPath)
+
+	if bucket == "" {
+		buckets, err := s.client.ListBuckets(ctx)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]DirEntry, 0, len(buckets))
+		for _, b := range buckets {
+			entries = append(entries, DirEntry{
+				Name:    b.Name,
+				ModTime: b.CreationDate,
+				Mode:    os.ModeDir | 0755,
+				IsDir:   true,
+			})
+		}
+		return entries, nil
+	}
+
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+
+	var entries []DirEntry
+	for obj := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if obj.Key == prefix {
+			continue // directory marker of dirPath itself
+		}
+		name := strings.TrimPrefix(obj.Key, prefix)
+		if strings.HasSuffix(name, "/") {
+			entries = append(entries, DirEntry{
+				Name:  strings.TrimSuffix(name, "/"),
+				Mode:  os.ModeDir | 0755,
+				IsDir: true,
+			})
+			continue
+		}
+		entries = append(entries, DirEntry{
+			Name:    name,
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+			Mode:    0644,
+		})
+	}
+	return entries, nil
+}
+
+// hasPrefix reports whether any object lives under prefix.
+func (s *S3FS) hasPrefix(bucket, prefix string) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for obj := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, MaxKeys: 1}) {
+		if obj.Err != nil {
+			return false, obj.Err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *S3FS) Stat(filePath string) (FileInfo, error) {
+	ctx := context.Background()
+	bucket, key := s.split(filePath)
+	dirInfo := FileInfo{Name: path.Base(filePath), Mode: os.ModeDir | 0755, IsDir: true}
+
+	if bucket == "" {
+		return dirInfo, nil
+	}
+	if key == "" {
+		ok, err := s.client.BucketExists(ctx, bucket)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		if !ok {
+			return FileInfo{}, &os.PathError{Op: "stat", Path: filePath, Err: os.ErrNotExist}
+		}
+		return dirInfo, nil
+	}
+
+	obj, err := s.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return FileInfo{
+			Name:    path.Base(key),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+			Mode:    0644,
+		}, nil
+	}
+	if !s3NotFound(err) {
+		return FileInfo{}, err
+	}
+
+	ok, lerr := s.hasPrefix(bucket, key+"/")
+	if lerr != nil {
+		return FileInfo{}, lerr
+	}
+	if !ok {
+		return FileInfo{}, &os.PathError{Op: "stat", Path: filePath, Err: os.ErrNotExist}
+	}
+	return dirInfo, nil
+}
+
+func (s *S3FS) Lstat(filePath string) (FileInfo, error) {
+	return s.Stat(filePath)
+}
+
+func (s *S3FS) Readlink(_ string) (string, error) {
+	return "", fmt.Errorf("symlinks not supported on S3")
+}
+
+// Open returns the object for reading. The returned *minio.Object also
+// implements io.ReaderAt and io.Seeker, which issue ranged GET requests.
+func (s *S3FS) Open(filePath string) (io.ReadCloser, error) {
+	bucket, key := s.split(filePath)
+	if key == "" {
+		return nil, fmt.Errorf("%s is a bucket", filePath)
+	}
+	obj, err := s.client.GetObject(context.Background(), bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; stat now so a missing key fails here, not on Read.
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Create streams the upload; data of unknown length goes up as a multipart upload.
+func (s *S3FS) Create(filePath string, _ fs.FileMode) (io.WriteCloser, error) {
+	bucket, key := s.split(filePath)
+	if key == "" {
+		return nil, fmt.Errorf("cannot create a file outside a bucket: %s", filePath)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := s.client.PutObject(context.Background(), bucket, key, pr, -1,
+			minio.PutObjectOptions{PartSize: s3PartSize})
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	return &pipeWriteCloser{pw: pw, done: done}, nil
+}
+
+func (s *S3FS) MkdirAll(dirPath string, _ fs.FileMode) error {
+	ctx := context.Background()
+	bucket, key := s.split(dirPath)
+	if bucket == "" {
+		return nil
+	}
+
+	ok, err := s.client.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: s.region}); err != nil {
+			return err
+		}
+	}
+	if key == "" {
+		return nil
+	}
+
+	if ok, err := s.hasPrefix(bucket, key+"/"); err != nil || ok {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, bucket, key+"/", strings.NewReader(""), 0, minio.PutObjectOptions{})
+	return err
+}
+
+func (s *S3FS) Remove(filePath string) error {
+	ctx := context.Background()
+	bucket, key := s.split(filePath)
+	if bucket == "" {
+		return fmt.Errorf("cannot remove /")
+	}
+	if key == "" {
+		return s.client.RemoveBucket(ctx, bucket)
+	}
+
+	fi, err := s.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir {
+		key += "/"
+	}
+	return s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
+}
+
+// RemoveAll deletes every object under the path, and the bucket itself if
+// the path names one.
+func (s *S3FS) RemoveAll(filePath string) error {
+	ctx := context.Background()
+	bucket, key := s.split(filePath)
+	if bucket == "" {
+		return fmt.Errorf("cannot remove /")
+	}
+
+	if key != "" {
+		err := s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
+		if err != nil && !s3NotFound(err) {
+			return err
+		}
+	}
+
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+	objects := s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	for rerr := range s.client.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{}) {
+		if rerr.Err != nil {
+			return rerr.Err
+		}
+	}
+
+	if key == "" {
+		return s.client.RemoveBucket(ctx, bucket)
+	}
+	return nil
+}
+
+// Rename copies objects server-side and deletes the originals, since S3 has
+// no native rename.
+func (s *S3FS) Rename(oldpath, newpath string) error {
+	ctx := context.Background()
+	srcBucket, srcKey := s.split(oldpath)
+	dstBucket, dstKey := s.split(newpath)
+	if srcKey == "" || dstKey == "" {
+		return fmt.Errorf("cannot rename buckets")
+	}
+
+	fi, err := s.Stat(oldpath)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir {
+		return s.moveObject(ctx, srcBucket, srcKey, dstBucket, dstKey)
+	}
+
+	for obj := range s.client.ListObjects(ctx, srcBucket, minio.ListObjectsOptions{Prefix: srcKey + "/", Recursive: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		rel := strings.TrimPrefix(obj.Key, srcKey)
+		if err := s.moveObject(ctx, srcBucket, obj.Key, dstBucket, dstKey+rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3FS) moveObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: dstBucket, Object: dstKey},
+		minio.CopySrcOptions{Bucket: srcBucket, Object: srcKey})
+	if err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, srcBucket, srcKey, minio.RemoveObjectOptions{})
+}
+
+func (s *S3FS) ReadFile(filePath string) ([]byte, error) {
+	rc, err := s.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func (s *S3FS) Walk(root string, fn WalkFunc) error {
+	return s.walkDir(root, fn)
+}
+
+func (s *S3FS) walkDir(dir string, fn WalkFunc) error {
+	fi, err := s.Stat(dir)
+	if err != nil {
+		return fn(dir, FileInfo{}, err)
+	}
+
+	if err := fn(dir, fi, nil); err != nil {
+		return err
+	}
+
+	if !fi.IsDir {
+		return nil
+	}
+
+	entries, err := s.ReadDir(dir)
+	if err != nil {
+		return fn(dir, fi, err)
+	}
+
+	for _, entry := range entries {
+		childPath := path.Join(dir, entry.Name)
+		if entry.IsDir {
+			if err := s.walkDir(childPath, fn); err != nil {
+				return err
+			}
+		} else {
+			childInfo := FileInfo{
+				Name:    entry.Name,
+				Size:    entry.Size,
+				ModTime: entry.ModTime,
+				Mode:    entry.Mode,
+				IsDir:   false,
+			}
+			if err := fn(childPath, childInfo, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *S3FS) Chmod(_ string, _ os.FileMode) error {
+	return fmt.Errorf("chmod not supported on S3")
+}
+
+func (s *S3FS) Chown(_ string, _, _ int) error {
+	return fmt.Errorf("chown not supported on S3")
+}
+
+func (s *S3FS) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (s *S3FS) Dir(p string) string {
+	return path.Dir(p)
+}
+
+func (s *S3FS) Base(p string) string {
+	return path.Base(p)
+}
+
+func (s *S3FS) IsLocal() bool {
+	return false
+}
+
+// Ping checks that the endpoint still answers. Any S3 error response, even
+// AccessDenied, means the service is reachable.
+func (s *S3FS) Ping() error {
+	_, err := s.client.ListBuckets(context.Background())
+	if err != nil && minio.ToErrorResponse(err).Code != "" {
+		return nil
+	}
+	return err
+}
+
+func (s *S3FS) Close() error {
+	return nil
+}
@@ -0,0 +1,322 @@
+package vfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/feherkaroly/vc/internal/config"
+)
+
+// fakeS3 is an in-memory S3 endpoint with just the calls S3FS makes.
+// Objects are keyed by "bucket/key"; a bucket is an entry ending in "/".
+type fakeS3 struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	uploads   map[string][][]byte // upload ID to parts
+	multipart int                 // completed multipart uploads
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+	_, exists := f.objects[bucket+"/"]
+	switch {
+	case bucket == "":
+		type entry struct{ Name string }
+		var res struct {
+			XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+			Buckets []entry  `xml:"Buckets>Bucket"`
+		}
+		for k := range f.objects {
+			if b, ok := strings.CutSuffix(k, "/"); ok && !strings.Contains(b, "/") {
+				res.Buckets = append(res.Buckets, entry{b})
+			}
+		}
+		writeXML(w, res)
+	case key == "" && r.Method == http.MethodPut:
+		f.objects[bucket+"/"] = nil
+	case !exists:
+		s3Error(w, http.StatusNotFound, "NoSuchBucket")
+	case key == "" && r.Method == http.MethodDelete:
+		delete(f.objects, bucket+"/")
+		w.WriteHeader(http.StatusNoContent)
+	case key == "" && r.Method == http.MethodPost && q.Has("delete"):
+		var req struct {
+			Keys []string `xml:"Object>Key"`
+		}
+		xml.NewDecoder(r.Body).Decode(&req)
+		for _, k := range req.Keys {
+			delete(f.objects, bucket+"/"+k)
+		}
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"DeleteResult"`
+		}{})
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, bucket, q.Get("prefix"), q.Get("delimiter"))
+	case key == "": // HEAD: the bucket exists
+
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = nil
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			UploadId string
+		}{UploadId: id})
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		parts := f.uploads[q.Get("uploadId")]
+		for len(parts) < n {
+			parts = append(parts, nil)
+		}
+		parts[n-1] = readBody(r)
+		f.uploads[q.Get("uploadId")] = parts
+		w.Header().Set("ETag", `"part`+strconv.Itoa(n)+`"`)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		f.objects[bucket+"/"+key] = bytes.Join(f.uploads[q.Get("uploadId")], nil)
+		delete(f.uploads, q.Get("uploadId"))
+		f.multipart++
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			ETag    string
+		}{Bucket: bucket, ETag: `"multipart"`})
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(f.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		src, _ := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"))
+		data, ok := f.objects[src]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		f.objects[bucket+"/"+key] = data
+		writeXML(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			ETag         string
+			LastModified string
+		}{ETag: `"copy"`, LastModified: "2024-01-02T03:04:05.000Z"})
+	case r.Method == http.MethodPut:
+		f.objects[bucket+"/"+key] = readBody(r)
+		w.Header().Set("ETag", `"put"`)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		data, ok := f.objects[bucket+"/"+key]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"get"`)
+		w.Header().Set("Last-Modified", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat))
+		http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(data))
+	}
+}
+
+// list answers ListObjectsV2, rolling keys up to common prefixes at the
+// delimiter.
+func (f *fakeS3) list(w http.ResponseWriter, bucket, prefix, delim string) {
+	type object struct {
+		Key          string
+		LastModified string
+		Size         int
+	}
+	type commonPrefix struct{ Prefix string }
+	var res struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Name           string
+		Prefix         string
+		Delimiter      string
+		KeyCount       int
+		MaxKeys        int
+		IsTruncated    bool
+		Contents       []object
+		CommonPrefixes []commonPrefix
+	}
+	res.Name, res.Prefix, res.Delimiter, res.MaxKeys = bucket, prefix, delim, 1000
+
+	var keys []string
+	for k := range f.objects {
+		if k, ok := strings.CutPrefix(k, bucket+"/"); ok && k != "" && strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if i := strings.Index(k[len(prefix):], delim); delim != "" && i >= 0 {
+			p := k[:len(prefix)+i+len(delim)]
+			if n := len(res.CommonPrefixes); n == 0 || res.CommonPrefixes[n-1].Prefix != p {
+				res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{p})
+			}
+			continue
+		}
+		res.Contents = append(res.Contents, object{k, "2024-01-02T03:04:05.000Z", len(f.objects[bucket+"/"+k])})
+	}
+	res.KeyCount = len(res.Contents) + len(res.CommonPrefixes)
+	writeXML(w, res)
+}
+
+// readBody returns a request's payload, taking off the aws-chunked framing
+// of streaming signatures.
+func readBody(r *http.Request) []byte {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		data, _ := io.ReadAll(r.Body)
+		return data
+	}
+	var data []byte
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		size, _ := strconv.ParseInt(strings.SplitN(strings.TrimSpace(line), ";", 2)[0], 16, 64)
+		if err != nil || size == 0 {
+			return data
+		}
+		chunk := make([]byte, size)
+		io.ReadFull(br, chunk)
+		data = append(data, chunk...)
+		br.ReadString('\n')
+	}
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	data, _ := xml.Marshal(v)
+	w.Write(data)
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func newTestS3(t *testing.T) (*S3FS, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}, uploads: map[string][][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	s, err := NewS3FS(config.ServerConfig{
+		Protocol: "s3-http", Host: host, Port: p,
+		User: "access", Password: "secret", Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return s, fake
+}
+
+func TestS3FS(t *testing.T) {
+	s, _ := newTestS3(t)
+
+	if err := s.MkdirAll("/photos/empty", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, s, "/photos/a.jpg", "jpeg data")
+	writeFile(t, s, "/photos/2024/b.jpg", "more jpeg data")
+
+	// The bucket is a top-level directory.
+	entries, err := s.ReadDir("/")
+	if err != nil || len(entries) != 1 || entries[0].Name != "photos" || !entries[0].IsDir {
+		t.Errorf("readdir / = %+v, %v", entries, err)
+	}
+
+	// Key prefixes list as directories, with or without a marker object.
+	entries, err = s.ReadDir("/photos")
+	if err != nil {
+		t.Fatalf("readdir: %v", err)
+	}
+	got := map[string]DirEntry{}
+	for _, e := range entries {
+		got[e.Name] = e
+	}
+	if len(got) != 3 || !got["2024"].IsDir || !got["empty"].IsDir || got["a.jpg"].IsDir || got["a.jpg"].Size != 9 {
+		t.Errorf("readdir /photos = %+v", entries)
+	}
+
+	if fi, err := s.Stat("/photos/2024"); err != nil || !fi.IsDir {
+		t.Errorf("stat of a prefix = %+v, %v", fi, err)
+	}
+	if fi, err := s.Stat("/photos/2024/b.jpg"); err != nil || fi.IsDir || fi.Size != 14 || fi.Name != "b.jpg" {
+		t.Errorf("stat of an object = %+v, %v", fi, err)
+	}
+	if _, err := s.Stat("/photos/missing"); err == nil {
+		t.Error("stat of a missing key succeeded")
+	}
+	if data := readFile(t, s, "/photos/2024/b.jpg"); data != "more jpeg data" {
+		t.Errorf("download = %q, want %q", data, "more jpeg data")
+	}
+
+	// A directory is renamed object by object.
+	if err := s.Rename("/photos/2024", "/photos/old"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if _, err := s.Stat("/photos/2024/b.jpg"); err == nil {
+		t.Error("object still under the old prefix after rename")
+	}
+	if data := readFile(t, s, "/photos/old/b.jpg"); data != "more jpeg data" {
+		t.Errorf("renamed object = %q, want %q", data, "more jpeg data")
+	}
+
+	if err := s.RemoveAll("/photos/old"); err != nil {
+		t.Fatalf("removeall: %v", err)
+	}
+	if _, err := s.Stat("/photos/old"); err == nil {
+		t.Error("prefix still exists after removeall")
+	}
+	if err := s.RemoveAll("/photos"); err != nil {
+		t.Fatalf("removeall of a bucket: %v", err)
+	}
+	if entries, err := s.ReadDir("/"); err != nil || len(entries) != 0 {
+		t.Errorf("buckets after removeall = %+v, %v", entries, err)
+	}
+}
+
+func TestS3Multipart(t *testing.T) {
+	s, fake := newTestS3(t)
+	if err := s.MkdirAll("/big", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	// More than one part, so the upload can't be sent as a single PUT.
+	data := bytes.Repeat([]byte("0123456789abcdef"), s3PartSize/16+1000)
+	wc, err := s.Create("/big/file.bin", 0644)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := wc.Write(data); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := wc.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if fake.multipart != 1 {
+		t.Errorf("%d multipart uploads completed, want 1", fake.multipart)
+	}
+	if got := readFile(t, s, "/big/file.bin"); got != string(data) {
+		t.Errorf("download of %d bytes differs from the %d uploaded", len(got), len(data))
+	}
+}
@@ -1,12 +1,14 @@
 package vfs
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
 	"net"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,10 +21,58 @@ import (
 type SFTPFS struct {
 	client    *sftp.Client
 	sshClient *ssh.Client
+	remote    *sshRemote
 }
 
 // NewSFTPFS establishes an SFTP connection based on the given server config.
 func NewSFTPFS(cfg config.ServerConfig) (*SFTPFS, error) {
+	sshClient, err := dialSSH(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSFTPFromClient(newSSHRemote(cfg, sshClient))
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// dialSSHFS connects over SSH and uses SFTP when the server offers it,
+// falling back to shell commands on hosts without the SFTP subsystem.
+func dialSSHFS(cfg config.ServerConfig) (FileSystem, error) {
+	sshClient, err := dialSSH(cfg)
+	if err != nil {
+		return nil, err
+	}
+	remote := newSSHRemote(cfg, sshClient)
+	s, err := newSFTPFromClient(remote)
+	if err == nil {
+		return s, nil
+	}
+	shell, serr := newShellFS(remote)
+	if serr != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("%w (shell fallback: %v)", err, serr)
+	}
+	return shell, nil
+}
+
+func newSFTPFromClient(remote *sshRemote) (*SFTPFS, error) {
+	sftpClient, err := sftp.NewClient(remote.client, sftp.UseConcurrentWrites(true))
+	if err != nil {
+		return nil, fmt.Errorf("SFTP client: %w", err)
+	}
+
+	return &SFTPFS{
+		client:    sftpClient,
+		sshClient: remote.client,
+		remote:    remote,
+	}, nil
+}
+
+// dialSSH opens an authenticated SSH connection based on the given server config.
+func dialSSH(cfg config.ServerConfig) (*ssh.Client, error) {
 	port := cfg.Port
 	if port == 0 {
 		port = 22
@@ -60,7 +110,7 @@ func NewSFTPFS(cfg config.ServerConfig) (*SFTPFS, error) {
 		Timeout:         10 * time.Second,
 	}
 
-	addr := fmt.Sprintf("%s:%d", cfg.Host, port)
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
 
 	// Use net.DialTimeout + deadline so the SSH handshake is also bounded
 	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
@@ -76,18 +126,7 @@ func NewSFTPFS(cfg config.ServerConfig) (*SFTPFS, error) {
 	}
 	conn.SetDeadline(time.Time{}) // clear deadline after successful handshake
 
-	sshClient := ssh.NewClient(c, chans, reqs)
-
-	sftpClient, err := sftp.NewClient(sshClient, sftp.UseConcurrentWrites(true))
-	if err != nil {
-		sshClient.Close()
-		return nil, fmt.Errorf("SFTP client: %w", err)
-	}
-
-	return &SFTPFS{
-		client:    sftpClient,
-		sshClient: sshClient,
-	}, nil
+	return ssh.NewClient(c, chans, reqs), nil
 }
 
 func (s *SFTPFS) ReadDir(dirPath string) ([]DirEntry, error) {
@@ -168,8 +207,21 @@ func (s *SFTPFS) Remove(filePath string) error {
 	return s.client.Remove(filePath)
 }
 
-// RemoveAll recursively removes a file or directory.
+// RemoveAll deletes the tree with rm -rf on the server when the SSH session
+// allows exec, falling back to removing entries one by one over SFTP.
 func (s *SFTPFS) RemoveAll(filePath string) error {
+	if path.Clean(filePath) != "/" && s.remote.canExec() {
+		if _, err := runSSH(s.sshClient, "rm -rf -- "+shellQuote(filePath), nil); err == nil {
+			// Trust what is left rather than the exit status.
+			if _, err := s.client.Lstat(filePath); isNotExist(err) {
+				return nil
+			}
+		}
+	}
+	return s.removeAll(filePath)
+}
+
+func (s *SFTPFS) removeAll(filePath string) error {
 	fi, err := s.client.Lstat(filePath)
 	if err != nil {
 		if isNotExist(err) {
@@ -189,7 +241,7 @@ func (s *SFTPFS) RemoveAll(filePath string) error {
 
 	for _, entry := range entries {
 		childPath := path.Join(filePath, entry.Name())
-		if err := s.RemoveAll(childPath); err != nil {
+		if err := s.removeAll(childPath); err != nil {
 			return err
 		}
 	}
@@ -275,6 +327,40 @@ func (s *SFTPFS) IsLocal() bool {
 	return false
 }
 
+// DirSize totals a directory tree with du on the server. It fails if the
+// SSH session does not allow exec; callers then walk the tree over SFTP.
+func (s *SFTPFS) DirSize(dirPath string) (int64, error) {
+	if !s.remote.canExec() {
+		return 0, errNoExec
+	}
+	return execDirSize(s.sshClient, dirPath)
+}
+
+// CopyWithin copies with cp on the server. It fails if the SSH session does
+// not allow exec; callers then stream the data over SFTP.
+func (s *SFTPFS) CopyWithin(ctx context.Context, src, dst string, preserveMode bool) error {
+	if !s.remote.canExec() {
+		return errNoExec
+	}
+	return execCopy(ctx, s.sshClient, src, dst, preserveMode)
+}
+
+// TransferTo sends src straight to dst when dst is another SSH server.
+func (s *SFTPFS) TransferTo(ctx context.Context, src string, dst FileSystem, dstPath string, preserveMode bool) error {
+	return s.remote.transferTo(ctx, s, src, dst, dstPath, preserveMode)
+}
+
+// RunCommand executes command in dir on the remote host over the same SSH connection.
+func (s *SFTPFS) RunCommand(dir, command string) ([]byte, error) {
+	return runInDir(s.sshClient, dir, command)
+}
+
+// Ping checks that the SFTP session is still alive.
+func (s *SFTPFS) Ping() error {
+	_, err := s.client.Getwd()
+	return err
+}
+
 func (s *SFTPFS) Close() error {
 	s.client.Close()
 	return s.sshClient.Close()
@@ -0,0 +1,525 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ShellFS implements FileSystem by running POSIX shell commands (stat, cat,
+// mkdir, mv, rm, ...) over SSH. It is used for hosts that allow SSH logins
+// but have no SFTP subsystem, such as many network appliances.
+type ShellFS struct {
+	client *ssh.Client
+	remote *sshRemote
+}
+
+func newShellFS(remote *sshRemote) (*ShellFS, error) {
+	s := &ShellFS{client: remote.client, remote: remote}
+	if _, err := s.run("stat -c %s / >/dev/null", nil); err != nil {
+		return nil, fmt.Errorf("remote shell: %w", err)
+	}
+	return s, nil
+}
+
+// shellQuote quotes s for safe use as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// errNoExec is returned by server-side operations when the SSH session
+// doesn't run commands.
+var errNoExec = errors.New("server does not run commands")
+
+// runSSH runs cmd in a new session and returns its stdout. A failing command
+// is reported with its stderr output.
+func runSSH(client *ssh.Client, cmd string, stdin io.Reader) ([]byte, error) {
+	return runSSHContext(context.Background(), client, cmd, stdin)
+}
+
+// runSSHContext is like runSSH but closes the session when ctx is cancelled.
+func runSSHContext(ctx context.Context, client *ssh.Client, cmd string, stdin io.Reader) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	return runSession(ctx, session, cmd, stdin)
+}
+
+// runSession runs cmd in an already prepared session.
+func runSession(ctx context.Context, session *ssh.Session, cmd string, stdin io.Reader) ([]byte, error) {
+	stop := context.AfterFunc(ctx, func() {
+		session.Signal(ssh.SIGTERM)
+		session.Close()
+	})
+	defer stop()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(cmd); err != nil {
+		if ctx.Err() != nil {
+			return stdout.Bytes(), ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.Bytes(), fmt.Errorf("%s: %w", msg, err)
+		}
+		return stdout.Bytes(), err
+	}
+	return stdout.Bytes(), nil
+}
+
+// execDirSize runs du on the server. It reports apparent sizes; unlike a
+// walk it also counts the directory entries themselves.
+func execDirSize(client *ssh.Client, dirPath string) (int64, error) {
+	out, err := runSSH(client, "du -sb -- "+shellQuote(dirPath), nil)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected du output: %q", out)
+	}
+	return strconv.ParseInt(fields[0], 10, 64)
+}
+
+// execCopy copies src to dst with cp on the server. A directory's contents
+// are merged into dst, matching the relayed copy.
+func execCopy(ctx context.Context, client *ssh.Client, src, dst string, preserveMode bool) error {
+	flags := "-R -P"
+	if preserveMode {
+		flags = "-a"
+	}
+	s, d := shellQuote(src), shellQuote(dst)
+	script := "mkdir -p -- " + shellQuote(path.Dir(dst)) + " && " +
+		"if [ -d " + s + " ] && [ ! -L " + s + " ]; then " +
+		"mkdir -p -- " + d + " && cp " + flags + " -- " + s + "/. " + d + "; " +
+		"else cp " + flags + " -- " + s + " " + d + "; fi"
+	_, err := runSSHContext(ctx, client, script, nil)
+	return err
+}
+
+// runInDir runs a user command in dir and returns its combined output.
+func runInDir(client *ssh.Client, dir, command string) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	return session.CombinedOutput("cd " + shellQuote(dir) + " && " + command)
+}
+
+func (s *ShellFS) run(cmd string, stdin io.Reader) ([]byte, error) {
+	return runSSH(s.client, cmd, stdin)
+}
+
+// statFormat prints the raw mode (hex), size, mtime and name of a file.
+const statFormat = "'%f %s %Y %n'"
+
+// parseStatLine parses one line produced by stat -c statFormat.
+func parseStatLine(line string) (FileInfo, error) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 {
+		return FileInfo{}, fmt.Errorf("unexpected stat output: %q", line)
+	}
+	raw, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("unexpected stat output: %q", line)
+	}
+	size, _ := strconv.ParseInt(fields[1], 10, 64)
+	mtime, _ := strconv.ParseInt(fields[2], 10, 64)
+	mode := unixMode(uint32(raw))
+	return FileInfo{
+		Name:    path.Base(fields[3]),
+		Size:    size,
+		ModTime: time.Unix(mtime, 0),
+		Mode:    mode,
+		IsDir:   mode.IsDir(),
+	}, nil
+}
+
+// unixMode converts a raw st_mode value to an os.FileMode.
+func unixMode(raw uint32) os.FileMode {
+	mode := os.FileMode(raw & 0777)
+	switch raw & 0170000 {
+	case 0040000:
+		mode |= os.ModeDir
+	case 0120000:
+		mode |= os.ModeSymlink
+	case 0010000:
+		mode |= os.ModeNamedPipe
+	case 0140000:
+		mode |= os.ModeSocket
+	case 0020000:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 0060000:
+		mode |= os.ModeDevice
+	}
+	if raw&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if raw&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if raw&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// notExist turns a failed stat of a missing file into fs.ErrNotExist.
+func notExist(op, p string, err error) error {
+	if err != nil && strings.Contains(err.Error(), "No such file") {
+		return &os.PathError{Op: op, Path: p, Err: fs.ErrNotExist}
+	}
+	return err
+}
+
+func (s *ShellFS) ReadDir(dirPath string) ([]DirEntry, error) {
+	// One stat per entry, including dot files; symlinks are listed with the
+	// type of their target (stat -L) like the SFTP backend does.
+	script := "cd " + shellQuote(dirPath) + " || exit 1; " +
+		"for f in .[!.]* ..?* *; do " +
+		"if [ -L \"$f\" ]; then printf 'L '; stat -L -c " + statFormat + " -- \"$f\" 2>/dev/null || stat -c " + statFormat + " -- \"$f\"; " +
+		"elif [ -e \"$f\" ]; then printf 'F '; stat -c " + statFormat + " -- \"$f\"; fi; " +
+		"done"
+	out, err := s.run(script, nil)
+	if err != nil {
+		return nil, notExist("readdir", dirPath, err)
+	}
+
+	var entries []DirEntry
+	var links []int
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if len(line) < 2 {
+			continue
+		}
+		fi, err := parseStatLine(line[2:])
+		if err != nil {
+			return nil, err
+		}
+		entry := DirEntry{
+			Name:    fi.Name,
+			Size:    fi.Size,
+			ModTime: fi.ModTime,
+			Mode:    fi.Mode,
+			IsDir:   fi.IsDir,
+			IsLink:  line[0] == 'L',
+		}
+		if entry.IsLink {
+			links = append(links, len(entries))
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(links) > 0 {
+		var cmd strings.Builder
+		cmd.WriteString("cd " + shellQuote(dirPath) + " || exit 1; for f in")
+		for _, i := range links {
+			cmd.WriteString(" " + shellQuote(entries[i].Name))
+		}
+		cmd.WriteString("; do readlink -- \"$f\" || echo; done")
+		if out, err := s.run(cmd.String(), nil); err == nil {
+			targets := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+			for j, i := range links {
+				if j < len(targets) {
+					entries[i].LinkTo = targets[j]
+				}
+			}
+		}
+	}
+	return entries, nil
+}
+
+func (s *ShellFS) stat(op, filePath string, follow bool) (FileInfo, error) {
+	flag := ""
+	if follow {
+		flag = "-L "
+	}
+	out, err := s.run("stat "+flag+"-c "+statFormat+" -- "+shellQuote(filePath), nil)
+	if err != nil {
+		return FileInfo{}, notExist(op, filePath, err)
+	}
+	fi, err := parseStatLine(strings.TrimRight(string(out), "\n"))
+	if err != nil {
+		return FileInfo{}, err
+	}
+	fi.Name = path.Base(filePath)
+	return fi, nil
+}
+
+func (s *ShellFS) Stat(filePath string) (FileInfo, error) {
+	return s.stat("stat", filePath, true)
+}
+
+func (s *ShellFS) Lstat(filePath string) (FileInfo, error) {
+	return s.stat("lstat", filePath, false)
+}
+
+func (s *ShellFS) Readlink(filePath string) (string, error) {
+	out, err := s.run("readlink -- "+shellQuote(filePath), nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// shellReader streams the stdout of a remote command.
+type shellReader struct {
+	session *ssh.Session
+	stdout  io.Reader
+	stderr  *bytes.Buffer
+	eof     bool
+}
+
+func (r *shellReader) Read(p []byte) (int, error) {
+	n, err := r.stdout.Read(p)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+func (r *shellReader) Close() error {
+	if !r.eof {
+		// Stopped early: dropping the channel ends the command.
+		return r.session.Close()
+	}
+	defer r.session.Close()
+	if err := r.session.Wait(); err != nil {
+		if msg := strings.TrimSpace(r.stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w", msg, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *ShellFS) Open(filePath string) (io.ReadCloser, error) {
+	return s.stream(filePath, "cat -- "+shellQuote(filePath))
+}
+
+// OpenAt starts reading at offset with tail, so the server skips the data
+// before it instead of sending it.
+func (s *ShellFS) OpenAt(filePath string, offset int64) (io.ReadCloser, error) {
+	return s.stream(filePath, "tail -c +"+strconv.FormatInt(offset+1, 10)+" -- "+shellQuote(filePath))
+}
+
+// stream runs cmd to read the regular file filePath from its stdout.
+func (s *ShellFS) stream(filePath, cmd string) (io.ReadCloser, error) {
+	fi, err := s.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir {
+		return nil, &os.PathError{Op: "open", Path: filePath, Err: errors.New("is a directory")}
+	}
+
+	session, err := s.client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	if err := session.Start(cmd); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &shellReader{session: session, stdout: stdout, stderr: &stderr}, nil
+}
+
+// shellWriter feeds the stdin of a remote command and waits for it on Close.
+type shellWriter struct {
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stderr  *bytes.Buffer
+}
+
+func (w *shellWriter) Write(p []byte) (int, error) {
+	return w.stdin.Write(p)
+}
+
+func (w *shellWriter) Close() error {
+	defer w.session.Close()
+	w.stdin.Close()
+	if err := w.session.Wait(); err != nil {
+		if msg := strings.TrimSpace(w.stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w", msg, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *ShellFS) Create(filePath string, mode fs.FileMode) (io.WriteCloser, error) {
+	session, err := s.client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	q := shellQuote(filePath)
+	cmd := fmt.Sprintf("cat > %s && chmod %o %s", q, mode.Perm(), q)
+	if err := session.Start(cmd); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &shellWriter{session: session, stdin: stdin, stderr: &stderr}, nil
+}
+
+func (s *ShellFS) MkdirAll(dirPath string, perm fs.FileMode) error {
+	_, err := s.run(fmt.Sprintf("mkdir -p -m %o -- %s", perm.Perm(), shellQuote(dirPath)), nil)
+	return err
+}
+
+func (s *ShellFS) Remove(filePath string) error {
+	q := shellQuote(filePath)
+	_, err := s.run("if [ -d "+q+" ] && [ ! -L "+q+" ]; then rmdir -- "+q+"; else rm -f -- "+q+"; fi", nil)
+	return err
+}
+
+func (s *ShellFS) RemoveAll(filePath string) error {
+	_, err := s.run("rm -rf -- "+shellQuote(filePath), nil)
+	return err
+}
+
+func (s *ShellFS) Rename(oldpath, newpath string) error {
+	_, err := s.run("mv -- "+shellQuote(oldpath)+" "+shellQuote(newpath), nil)
+	return err
+}
+
+func (s *ShellFS) ReadFile(filePath string) ([]byte, error) {
+	rc, err := s.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rc)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	return data, err
+}
+
+func (s *ShellFS) Walk(root string, fn WalkFunc) error {
+	return s.walkDir(root, fn)
+}
+
+func (s *ShellFS) walkDir(dir string, fn WalkFunc) error {
+	fi, err := s.Stat(dir)
+	if err != nil {
+		return fn(dir, FileInfo{}, err)
+	}
+
+	if err := fn(dir, fi, nil); err != nil {
+		return err
+	}
+
+	if !fi.IsDir {
+		return nil
+	}
+
+	entries, err := s.ReadDir(dir)
+	if err != nil {
+		return fn(dir, fi, err)
+	}
+
+	for _, entry := range entries {
+		childPath := path.Join(dir, entry.Name)
+		if entry.IsDir {
+			if err := s.walkDir(childPath, fn); err != nil {
+				return err
+			}
+		} else {
+			childInfo := FileInfo{
+				Name:    entry.Name,
+				Size:    entry.Size,
+				ModTime: entry.ModTime,
+				Mode:    entry.Mode,
+				IsDir:   false,
+			}
+			if err := fn(childPath, childInfo, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ShellFS) Chmod(filePath string, mode os.FileMode) error {
+	_, err := s.run(fmt.Sprintf("chmod %o -- %s", mode.Perm(), shellQuote(filePath)), nil)
+	return err
+}
+
+func (s *ShellFS) Chown(filePath string, uid, gid int) error {
+	_, err := s.run(fmt.Sprintf("chown %d:%d -- %s", uid, gid, shellQuote(filePath)), nil)
+	return err
+}
+
+func (s *ShellFS) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (s *ShellFS) Dir(p string) string {
+	return path.Dir(p)
+}
+
+func (s *ShellFS) Base(p string) string {
+	return path.Base(p)
+}
+
+func (s *ShellFS) IsLocal() bool {
+	return false
+}
+
+// DirSize totals a directory tree with du on the server.
+func (s *ShellFS) DirSize(dirPath string) (int64, error) {
+	return execDirSize(s.client, dirPath)
+}
+
+// CopyWithin copies with cp on the server.
+func (s *ShellFS) CopyWithin(ctx context.Context, src, dst string, preserveMode bool) error {
+	return execCopy(ctx, s.client, src, dst, preserveMode)
+}
+
+// TransferTo sends src straight to dst when dst is another SSH server.
+func (s *ShellFS) TransferTo(ctx context.Context, src string, dst FileSystem, dstPath string, preserveMode bool) error {
+	return s.remote.transferTo(ctx, s, src, dst, dstPath, preserveMode)
+}
+
+// RunCommand executes command in dir on the remote host.
+func (s *ShellFS) RunCommand(dir, command string) ([]byte, error) {
+	return runInDir(s.client, dir, command)
+}
+
+// Ping checks that the SSH connection still accepts new sessions.
+func (s *ShellFS) Ping() error {
+	_, err := s.run("true", nil)
+	return err
+}
+
+func (s *ShellFS) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,371 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feherkaroly/vc/internal/config"
+	"github.com/hirochachacha/go-smb2"
+)
+
+// SMBFS implements FileSystem over SMB2/3. Shares appear as top-level
+// directories and are mounted the first time they are used.
+type SMBFS struct {
+	conn    net.Conn
+	session *smb2.Session
+
+	mu     sync.Mutex
+	shares map[string]*smb2.Share
+}
+
+// NewSMBFS connects to an SMB server and authenticates with NTLM.
+func NewSMBFS(cfg config.ServerConfig) (*SMBFS, error) {
+	port := cfg.Port
+	if port == 0 {
+		port = 445
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("SMB dial %s: %w", addr, err)
+	}
+
+	d := &smb2.Dialer{
+		Initiator: &smb2.NTLMInitiator{
+			User:     cfg.User,
+			Password: cfg.Password,
+			Domain:   cfg.Domain,
+		},
+	}
+	session, err := d.Dial(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SMB login: %w", err)
+	}
+
+	return &SMBFS{
+		conn:    conn,
+		session: session,
+		shares:  make(map[string]*smb2.Share),
+	}, nil
+}
+
+// split turns an absolute path into a share name and a path within it.
+func (s *SMBFS) split(p string) (share, rel string) {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	share, rel, _ = strings.Cut(p, "/")
+	return share, rel
+}
+
+// share returns the mounted share, mounting it on first use.
+func (s *SMBFS) share(name string) (*smb2.Share, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sh, ok := s.shares[name]; ok {
+		return sh, nil
+	}
+	sh, err := s.session.Mount(name)
+	if err != nil {
+		return nil, err
+	}
+	s.shares[name] = sh
+	return sh, nil
+}
+
+// resolve splits p and mounts its share; paths at the top level are rejected.
+func (s *SMBFS) resolve(op, p string) (*smb2.Share, string, error) {
+	name, rel := s.split(p)
+	if name == "" {
+		return nil, "", &os.PathError{Op: op, Path: p, Err: fs.ErrPermission}
+	}
+	sh, err := s.share(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return sh, rel, nil
+}
+
+func (s *SMBFS) ReadDir(dirPath string) ([]DirEntry, error) {
+	name, rel := s.split(dirPath)
+
+	if name == "" {
+		names, err := s.session.ListSharenames()
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]DirEntry, 0, len(names))
+		for _, n := range names {
+			// Administrative shares (C$, IPC$, ...) are hidden but can
+			// still be reached by path.
+			if strings.HasSuffix(n, "$") {
+				continue
+			}
+			entries = append(entries, DirEntry{
+				Name:  n,
+				Mode:  os.ModeDir | 0755,
+				IsDir: true,
+			})
+		}
+		return entries, nil
+	}
+
+	sh, err := s.share(name)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := sh.ReadDir(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]DirEntry, 0, len(infos))
+	for _, fi := range infos {
+		entry := DirEntry{
+			Name:    fi.Name(),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			Mode:    fi.Mode(),
+			IsDir:   fi.IsDir(),
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			entry.IsLink = true
+			if target, err := sh.Readlink(path.Join(rel, fi.Name())); err == nil {
+				entry.LinkTo = target
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (s *SMBFS) stat(filePath string, lstat bool) (FileInfo, error) {
+	name, rel := s.split(filePath)
+	if name == "" {
+		return FileInfo{Name: "/", Mode: os.ModeDir | 0755, IsDir: true}, nil
+	}
+
+	sh, err := s.share(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	var fi os.FileInfo
+	if lstat {
+		fi, err = sh.Lstat(rel)
+	} else {
+		fi, err = sh.Stat(rel)
+	}
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{
+		Name:    path.Base(filePath),
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		Mode:    fi.Mode(),
+		IsDir:   fi.IsDir(),
+	}, nil
+}
+
+func (s *SMBFS) Stat(filePath string) (FileInfo, error) {
+	return s.stat(filePath, false)
+}
+
+func (s *SMBFS) Lstat(filePath string) (FileInfo, error) {
+	return s.stat(filePath, true)
+}
+
+func (s *SMBFS) Readlink(filePath string) (string, error) {
+	sh, rel, err := s.resolve("readlink", filePath)
+	if err != nil {
+		return "", err
+	}
+	return sh.Readlink(rel)
+}
+
+func (s *SMBFS) Open(filePath string) (io.ReadCloser, error) {
+	sh, rel, err := s.resolve("open", filePath)
+	if err != nil {
+		return nil, err
+	}
+	return sh.Open(rel)
+}
+
+func (s *SMBFS) Create(filePath string, mode fs.FileMode) (io.WriteCloser, error) {
+	sh, rel, err := s.resolve("create", filePath)
+	if err != nil {
+		return nil, err
+	}
+	return sh.OpenFile(rel, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+}
+
+func (s *SMBFS) MkdirAll(dirPath string, perm fs.FileMode) error {
+	name, rel := s.split(dirPath)
+	if name == "" {
+		return nil
+	}
+	sh, err := s.share(name)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return nil
+	}
+	return sh.MkdirAll(rel, perm)
+}
+
+func (s *SMBFS) Remove(filePath string) error {
+	sh, rel, err := s.resolve("remove", filePath)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return &os.PathError{Op: "remove", Path: filePath, Err: fs.ErrPermission}
+	}
+	return sh.Remove(rel)
+}
+
+func (s *SMBFS) RemoveAll(filePath string) error {
+	sh, rel, err := s.resolve("remove", filePath)
+	if err != nil {
+		return err
+	}
+	if rel == "" {
+		return &os.PathError{Op: "remove", Path: filePath, Err: fs.ErrPermission}
+	}
+	return sh.RemoveAll(rel)
+}
+
+func (s *SMBFS) Rename(oldpath, newpath string) error {
+	oldShare, oldRel := s.split(oldpath)
+	newShare, newRel := s.split(newpath)
+	if oldShare != newShare {
+		return fmt.Errorf("cannot rename across SMB shares")
+	}
+	sh, _, err := s.resolve("rename", oldpath)
+	if err != nil {
+		return err
+	}
+	return sh.Rename(oldRel, newRel)
+}
+
+func (s *SMBFS) ReadFile(filePath string) ([]byte, error) {
+	sh, rel, err := s.resolve("open", filePath)
+	if err != nil {
+		return nil, err
+	}
+	return sh.ReadFile(rel)
+}
+
+func (s *SMBFS) Walk(root string, fn WalkFunc) error {
+	return s.walkDir(root, fn)
+}
+
+func (s *SMBFS) walkDir(dir string, fn WalkFunc) error {
+	fi, err := s.Stat(dir)
+	if err != nil {
+		return fn(dir, FileInfo{}, err)
+	}
+
+	if err := fn(dir, fi, nil); err != nil {
+		return err
+	}
+
+	if !fi.IsDir {
+		return nil
+	}
+
+	entries, err := s.ReadDir(dir)
+	if err != nil {
+		return fn(dir, fi, err)
+	}
+
+	for _, entry := range entries {
+		childPath := path.Join(dir, entry.Name)
+		if entry.IsDir {
+			if err := s.walkDir(childPath, fn); err != nil {
+				return err
+			}
+		} else {
+			childInfo := FileInfo{
+				Name:    entry.Name,
+				Size:    entry.Size,
+				ModTime: entry.ModTime,
+				Mode:    entry.Mode,
+				IsDir:   false,
+			}
+			if err := fn(childPath, childInfo, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Chmod only toggles the read-only attribute; SMB has no Unix permissions.
+func (s *SMBFS) Chmod(filePath string, mode os.FileMode) error {
+	sh, rel, err := s.resolve("chmod", filePath)
+	if err != nil {
+		return err
+	}
+	return sh.Chmod(rel, mode)
+}
+
+func (s *SMBFS) Chown(_ string, _, _ int) error {
+	return fmt.Errorf("chown not supported over SMB")
+}
+
+func (s *SMBFS) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (s *SMBFS) Dir(p string) string {
+	return path.Dir(p)
+}
+
+func (s *SMBFS) Base(p string) string {
+	return path.Base(p)
+}
+
+func (s *SMBFS) IsLocal() bool {
+	return false
+}
+
+// Ping checks the session by stat-ing the root of a mounted share. With no
+// share mounted yet there is nothing to keep alive.
+func (s *SMBFS) Ping() error {
+	s.mu.Lock()
+	var sh *smb2.Share
+	for _, m := range s.shares {
+		sh = m
+		break
+	}
+	s.mu.Unlock()
+
+	if sh == nil {
+		return nil
+	}
+	_, err := sh.Stat("")
+	return err
+}
+
+func (s *SMBFS) Close() error {
+	s.mu.Lock()
+	for name, sh := range s.shares {
+		sh.Umount()
+		delete(s.shares, name)
+	}
+	s.mu.Unlock()
+
+	s.session.Logoff()
+	return s.conn.Close()
+}
@@ -0,0 +1,194 @@
+package vfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/feherkaroly/vc/internal/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// sshRemote is an SSH connection together with the address it was made to,
+// so another server can be told how to reach it.
+type sshRemote struct {
+	client *ssh.Client
+	user   string
+	host   string
+	port   int
+
+	agentOnce sync.Once
+	agentErr  error
+
+	execOnce sync.Once
+	execOK   bool
+}
+
+func newSSHRemote(cfg config.ServerConfig, client *ssh.Client) *sshRemote {
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+	return &sshRemote{client: client, user: cfg.User, host: cfg.Host, port: port}
+}
+
+// target formats p as an scp/rsync destination on this host.
+func (r *sshRemote) target(p string) string {
+	host := r.host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if r.user != "" {
+		host = r.user + "@" + host
+	}
+	return host + ":" + p
+}
+
+// canExec reports whether this host runs commands sent over the connection.
+// Accounts forced to internal-sftp or a restricted shell accept the request
+// but exit 0 without running anything, so the exit status proves nothing;
+// the probe checks the command's output instead.
+func (r *sshRemote) canExec() bool {
+	r.execOnce.Do(func() {
+		out, err := runSSH(r.client, "echo vc-exec-ok", nil)
+		r.execOK = err == nil && strings.TrimSpace(string(out)) == "vc-exec-ok"
+	})
+	return r.execOK
+}
+
+// has reports whether program is installed on this host.
+func (r *sshRemote) has(program string) bool {
+	if !r.canExec() {
+		return false
+	}
+	_, err := runSSH(r.client, "command -v "+program+" >/dev/null 2>&1", nil)
+	return err == nil
+}
+
+// forwardAgent makes the local ssh-agent available to sessions on this host,
+// so it can log in to the other server with the user's keys.
+func (r *sshRemote) forwardAgent() error {
+	r.agentOnce.Do(func() {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			r.agentErr = errors.New("no ssh-agent running")
+			return
+		}
+		r.agentErr = agent.ForwardToRemote(r.client, sock)
+	})
+	return r.agentErr
+}
+
+// sshRemoteOf returns the SSH connection behind fsys, or nil.
+func sshRemoteOf(fsys FileSystem) *sshRemote {
+	switch f := fsys.(type) {
+	case *SFTPFS:
+		return f.remote
+	case *ShellFS:
+		return f.remote
+	}
+	return nil
+}
+
+// transferTo copies src (on this host, accessed through srcFS) to dstPath on
+// dst by running rsync, or scp when rsync isn't available on both ends, on
+// this host. Authentication to dst relies on the forwarded local ssh-agent or
+// the keys of the remote account.
+func (r *sshRemote) transferTo(ctx context.Context, srcFS FileSystem, src string, dst FileSystem, dstPath string, preserveMode bool) error {
+	to := sshRemoteOf(dst)
+	if to == nil || to == r {
+		return ErrNoDirectTransfer
+	}
+
+	info, err := srcFS.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := dst.MkdirAll(path.Dir(dstPath), 0755); err != nil {
+		return err
+	}
+
+	// Unknown host keys are refused rather than trusted on first use: the
+	// user never sees them, so they can't be checked. The copy is relayed
+	// instead.
+	sshCmd := "ssh -p " + strconv.Itoa(to.port) + " -o BatchMode=yes -o StrictHostKeyChecking=yes"
+
+	var cmd string
+	switch {
+	case r.has("rsync") && to.has("rsync"):
+		flags := "-rl"
+		if preserveMode {
+			flags = "-a"
+		}
+		from := src
+		if info.IsDir {
+			from += "/"
+		}
+		cmd = "rsync " + flags + " -s -e " + shellQuote(sshCmd) + " -- " +
+			shellQuote(from) + " " + shellQuote(to.target(dstPath))
+	case r.has("scp"):
+		if info.IsDir {
+			// scp -r copies into an existing directory instead of merging
+			// with it, so leave that case to the relayed copy.
+			if _, err := dst.Lstat(dstPath); err == nil {
+				return ErrNoDirectTransfer
+			}
+		}
+		flags := "-B -r"
+		if preserveMode {
+			flags += " -p"
+		}
+		cmd = "scp " + flags + " -P " + strconv.Itoa(to.port) +
+			" -o StrictHostKeyChecking=yes -- " +
+			shellQuote(src) + " " + shellQuote(to.target(dstPath))
+	default:
+		return ErrNoDirectTransfer
+	}
+
+	session, err := r.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	if r.forwardAgent() == nil {
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			return fmt.Errorf("%w: agent forwarding: %v", ErrNoDirectTransfer, err)
+		}
+	}
+
+	_, err = runSession(ctx, session, cmd, nil)
+	if err != nil && ctx.Err() == nil && sshConnectFailed(err) {
+		return fmt.Errorf("%w: %v", ErrNoDirectTransfer, err)
+	}
+	return err
+}
+
+// sshConnectErrors are what ssh prints when it can't reach or log in to the
+// other server.
+var sshConnectErrors = []string{
+	"Host key verification failed",
+	"Permission denied",
+	"Could not resolve hostname",
+	"Connection refused",
+	"Connection timed out",
+	"No route to host",
+	"Connection closed by",
+}
+
+// sshConnectFailed reports whether a transfer failed because this host
+// couldn't connect to the other one, rather than while copying.
+func sshConnectFailed(err error) bool {
+	msg := err.Error()
+	for _, s := range sshConnectErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package vfs
 
 import (
+	"context"
+	"errors"
 	"io"
 	"io/fs"
 	"os"
@@ -52,3 +54,48 @@ type FileSystem interface {
 	IsLocal() bool
 	Close() error
 }
+
+// CommandRunner is implemented by remote filesystems that can execute shell
+// commands on the server. RunCommand returns the combined stdout and stderr.
+type CommandRunner interface {
+	RunCommand(dir, command string) ([]byte, error)
+}
+
+// DirSizer is implemented by remote filesystems that can total the size of
+// a directory tree on the server instead of walking it entry by entry.
+type DirSizer interface {
+	DirSize(path string) (int64, error)
+}
+
+// Copier is implemented by remote filesystems that can copy files within
+// the same server without streaming the data through this machine.
+type Copier interface {
+	CopyWithin(ctx context.Context, src, dst string, preserveMode bool) error
+}
+
+// DirectTransferer is implemented by remote filesystems that can send files
+// straight to another server, so the data does not pass through this machine.
+// dst must be an unwrapped filesystem (see Unwrap).
+type DirectTransferer interface {
+	TransferTo(ctx context.Context, src string, dst FileSystem, dstPath string, preserveMode bool) error
+}
+
+// Aborter is implemented by writers returned from Create that upload in the
+// background. Abort stops the upload with err instead of completing it, so
+// the server isn't left with what was sent so far as a whole file.
+type Aborter interface {
+	Abort(err error) error
+}
+
+// Abort ends writing w without completing it: uploads are aborted where
+// possible, other writers are closed.
+func Abort(w io.WriteCloser, err error) error {
+	if a, ok := w.(Aborter); ok {
+		return a.Abort(err)
+	}
+	return w.Close()
+}
+
+// ErrNoDirectTransfer is returned by TransferTo when the two servers can't
+// exchange data directly and the copy has to be relayed.
+var ErrNoDirectTransfer = errors.New("direct transfer not possible")
@@ -0,0 +1,222 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/feherkaroly/vc/internal/config"
+	"github.com/studio-b12/gowebdav"
+)
+
+// WebDAVFS implements FileSystem over WebDAV (HTTP or HTTPS).
+type WebDAVFS struct {
+	client *gowebdav.Client
+}
+
+// NewWebDAVFS connects to a WebDAV server based on the given server config.
+// Basic and digest authentication are negotiated automatically.
+func NewWebDAVFS(cfg config.ServerConfig) (*WebDAVFS, error) {
+	scheme := "http"
+	if cfg.Protocol == "webdavs" {
+		scheme = "https"
+	}
+	host := cfg.Host
+	if cfg.Port != 0 {
+		host = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	}
+	u := url.URL{Scheme: scheme, Host: host, Path: path.Join("/", cfg.RootPath)}
+
+	var certErr *CertError
+	client := gowebdav.NewClient(u.String(), cfg.User, cfg.Password)
+	client.SetTimeout(30 * time.Second)
+	client.SetTransport(&http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: newTLSConfig(cfg, &certErr),
+	})
+
+	if err := client.Connect(); err != nil {
+		if certErr != nil {
+			return nil, certErr
+		}
+		return nil, fmt.Errorf("WebDAV connect %s: %w", u.String(), err)
+	}
+
+	return &WebDAVFS{client: client}, nil
+}
+
+func (w *WebDAVFS) ReadDir(dirPath string) ([]DirEntry, error) {
+	infos, err := w.client.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]DirEntry, 0, len(infos))
+	for _, fi := range infos {
+		entries = append(entries, DirEntry{
+			Name:    fi.Name(),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			Mode:    fi.Mode(),
+			IsDir:   fi.IsDir(),
+		})
+	}
+	return entries, nil
+}
+
+func (w *WebDAVFS) Stat(filePath string) (FileInfo, error) {
+	fi, err := w.client.Stat(filePath)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{
+		Name:    path.Base(filePath),
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		Mode:    fi.Mode(),
+		IsDir:   fi.IsDir(),
+	}, nil
+}
+
+func (w *WebDAVFS) Lstat(filePath string) (FileInfo, error) {
+	return w.Stat(filePath)
+}
+
+func (w *WebDAVFS) Readlink(_ string) (string, error) {
+	return "", fmt.Errorf("symlinks not supported over WebDAV")
+}
+
+func (w *WebDAVFS) Open(filePath string) (io.ReadCloser, error) {
+	return w.client.ReadStream(filePath)
+}
+
+// OpenAt starts a download at offset with an HTTP range request. An open
+// range ("bytes=N-") is not used because the client's fallback for servers
+// that ignore ranges would then return nothing.
+func (w *WebDAVFS) OpenAt(filePath string, offset int64) (io.ReadCloser, error) {
+	return w.client.ReadStreamRange(filePath, offset, math.MaxInt64-offset)
+}
+
+func (w *WebDAVFS) Create(filePath string, mode fs.FileMode) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		err := w.client.WriteStream(filePath, pr, mode)
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	return &pipeWriteCloser{pw: pw, done: done}, nil
+}
+
+func (w *WebDAVFS) MkdirAll(dirPath string, perm fs.FileMode) error {
+	if fi, err := w.client.Stat(dirPath); err == nil && fi.IsDir() {
+		return nil
+	}
+	return w.client.MkdirAll(dirPath, perm)
+}
+
+func (w *WebDAVFS) Remove(filePath string) error {
+	return w.client.Remove(filePath)
+}
+
+// RemoveAll deletes a file or a whole collection; WebDAV DELETE is recursive.
+func (w *WebDAVFS) RemoveAll(filePath string) error {
+	return w.client.RemoveAll(filePath)
+}
+
+func (w *WebDAVFS) Rename(oldpath, newpath string) error {
+	return w.client.Rename(oldpath, newpath, false)
+}
+
+func (w *WebDAVFS) ReadFile(filePath string) ([]byte, error) {
+	return w.client.Read(filePath)
+}
+
+func (w *WebDAVFS) Walk(root string, fn WalkFunc) error {
+	return w.walkDir(root, fn)
+}
+
+func (w *WebDAVFS) walkDir(dir string, fn WalkFunc) error {
+	fi, err := w.Stat(dir)
+	if err != nil {
+		return fn(dir, FileInfo{}, err)
+	}
+
+	if err := fn(dir, fi, nil); err != nil {
+		return err
+	}
+
+	if !fi.IsDir {
+		return nil
+	}
+
+	entries, err := w.ReadDir(dir)
+	if err != nil {
+		return fn(dir, fi, err)
+	}
+
+	for _, entry := range entries {
+		childPath := path.Join(dir, entry.Name)
+		if entry.IsDir {
+			if err := w.walkDir(childPath, fn); err != nil {
+				return err
+			}
+		} else {
+			childInfo := FileInfo{
+				Name:    entry.Name,
+				Size:    entry.Size,
+				ModTime: entry.ModTime,
+				Mode:    entry.Mode,
+				IsDir:   false,
+			}
+			if err := fn(childPath, childInfo, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *WebDAVFS) Chmod(_ string, _ os.FileMode) error {
+	return fmt.Errorf("chmod not supported over WebDAV")
+}
+
+func (w *WebDAVFS) Chown(_ string, _, _ int) error {
+	return fmt.Errorf("chown not supported over WebDAV")
+}
+
+func (w *WebDAVFS) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (w *WebDAVFS) Dir(p string) string {
+	return path.Dir(p)
+}
+
+func (w *WebDAVFS) Base(p string) string {
+	return path.Base(p)
+}
+
+func (w *WebDAVFS) IsLocal() bool {
+	return false
+}
+
+// Ping checks that the server still answers requests.
+func (w *WebDAVFS) Ping() error {
+	_, err := w.client.Stat("/")
+	return err
+}
+
+func (w *WebDAVFS) Close() error {
+	return nil
+}
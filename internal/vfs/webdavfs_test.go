@@ -0,0 +1,176 @@
+package vfs
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/feherkaroly/vc/internal/config"
+)
+
+// newTestWebDAV starts an in-memory WebDAV server and connects to it.
+func newTestWebDAV(t *testing.T) *WebDAVFS {
+	t.Helper()
+	srv := httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	})
+	t.Cleanup(srv.Close)
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.Atoi(port)
+	w, err := NewWebDAVFS(config.ServerConfig{Protocol: "webdav", Host: host, Port: p})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return w
+}
+
+func writeFile(t *testing.T, fsys FileSystem, name, data string) {
+	t.Helper()
+	wc, err := fsys.Create(name, 0644)
+	if err != nil {
+		t.Fatalf("create %s: %v", name, err)
+	}
+	if _, err := io.WriteString(wc, data); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	if err := wc.Close(); err != nil {
+		t.Fatalf("close %s: %v", name, err)
+	}
+	if err := wc.Close(); err != nil {
+		t.Fatalf("second close %s: %v", name, err)
+	}
+}
+
+func readFile(t *testing.T, fsys FileSystem, name string) string {
+	t.Helper()
+	rc, err := fsys.Open(name)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestWebDAVFS(t *testing.T) {
+	w := newTestWebDAV(t)
+
+	if err := w.MkdirAll("/docs/old", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, w, "/docs/a.txt", "hello")
+	writeFile(t, w, "/docs/old/b.txt", "world!")
+
+	entries, err := w.ReadDir("/docs")
+	if err != nil {
+		t.Fatalf("readdir: %v", err)
+	}
+	got := map[string]DirEntry{}
+	for _, e := range entries {
+		got[e.Name] = e
+	}
+	if len(got) != 2 || got["a.txt"].Size != 5 || got["a.txt"].IsDir || !got["old"].IsDir {
+		t.Errorf("readdir /docs = %+v", entries)
+	}
+
+	fi, err := w.Stat("/docs/old/b.txt")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if fi.Name != "b.txt" || fi.Size != 6 || fi.IsDir {
+		t.Errorf("stat = %+v", fi)
+	}
+	if fi, err := w.Stat("/docs/old"); err != nil || !fi.IsDir {
+		t.Errorf("stat dir = %+v, %v", fi, err)
+	}
+
+	if s := readFile(t, w, "/docs/a.txt"); s != "hello" {
+		t.Errorf("open = %q, want %q", s, "hello")
+	}
+
+	if err := w.Rename("/docs/a.txt", "/docs/c.txt"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if _, err := w.Stat("/docs/a.txt"); err == nil {
+		t.Error("old name still exists after rename")
+	}
+	if s := readFile(t, w, "/docs/c.txt"); s != "hello" {
+		t.Errorf("renamed file = %q, want %q", s, "hello")
+	}
+
+	if err := w.RemoveAll("/docs/old"); err != nil {
+		t.Fatalf("removeall: %v", err)
+	}
+	if _, err := w.Stat("/docs/old/b.txt"); err == nil {
+		t.Error("file still exists after removeall")
+	}
+	entries, err = w.ReadDir("/docs")
+	if err != nil || len(entries) != 1 || entries[0].Name != "c.txt" {
+		t.Errorf("readdir after removeall = %+v, %v", entries, err)
+	}
+}
+
+func TestWebDAVAbort(t *testing.T) {
+	w := newTestWebDAV(t)
+
+	wc, err := w.Create("/partial.txt", 0644)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := io.WriteString(wc, "half of it"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := Abort(wc, context.Canceled); err == nil {
+		t.Error("aborted upload reported success")
+	}
+	if err := wc.Close(); err == nil { // must not block after Abort
+		t.Error("close after abort reported success")
+	}
+}
+
+func TestWebDAVOpenAt(t *testing.T) {
+	w := newTestWebDAV(t)
+	writeFile(t, w, "/a.txt", "hello, world")
+
+	rc, err := w.OpenAt("/a.txt", 2)
+	if err != nil {
+		t.Fatalf("open at: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "llo, world" {
+		t.Errorf("open at 2 = %q, want %q", data, "llo, world")
+	}
+
+	// Reads out of order restart the download at the offset.
+	f, err := OpenRandom(w, "/a.txt")
+	if err != nil {
+		t.Fatalf("open random: %v", err)
+	}
+	defer f.Close()
+	if !Streamed(f) {
+		t.Error("WebDAV file is not streamed")
+	}
+	buf := make([]byte, 5)
+	for _, c := range []struct {
+		off  int64
+		want string
+	}{{7, "world"}, {0, "hello"}, {3, "lo, w"}} {
+		if n, err := f.ReadAt(buf, c.off); err != nil || string(buf[:n]) != c.want {
+			t.Errorf("read at %d = %q, %v; want %q", c.off, buf[:n], err, c.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package viewer
+
+import (
+	"context"
+
+	"github.com/feherkaroly/vc/internal/textenc"
+)
+
+// Encoding returns the encoding the text is decoded with.
+func (v *Viewer) Encoding() *textenc.Encoding {
+	return v.src.enc
+}
+
+// SetConvertFunc sets the function called with the current encoding when
+// the user asks to convert the file to UTF-8.
+func (v *Viewer) SetConvertFunc(f func(enc *textenc.Encoding)) {
+	v.convertFunc = f
+}
+
+// setEncoding decodes the text with e from now on. Line boundaries depend
+// on the encoding, so the top line is realigned and lines are recounted.
+func (v *Viewer) setEncoding(e *textenc.Encoding) {
+	v.src.enc = e
+	if v.search != nil {
+		v.search.enc = e
+	}
+	if v.syntax != nil {
+		v.syntax.spans = nil
+	}
+	v.message = "Encoding: " + e.Name
+	if !v.hex {
+		v.top, v.topRow = v.src.lineStart(v.top), 0
+		if v.pastEnd() {
+			v.toEnd()
+		}
+	}
+
+	v.index.stop()
+	v.index = newLineIndex(v.src.size)
+	if v.background {
+		v.startIndex()
+	} else {
+		v.index.run(context.Background(), v.src.r, e, nil)
+	}
+}
+
+// convert asks the owner to convert the file from the current encoding.
+func (v *Viewer) convert() {
+	switch {
+	case v.convertFunc == nil:
+		return
+	case v.src.enc == textenc.UTF8:
+		v.message = "The file is shown as UTF-8 already; press e to pick its encoding"
+	default:
+		v.convertFunc(v.src.enc)
+	}
+}
@@ -0,0 +1,185 @@
+package viewer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// exifInfo is the part of a photo's EXIF data the viewer shows.
+type exifInfo struct {
+	orientation int      // 1 to 8 as defined by EXIF, 0 when missing
+	fields      []string // camera, lens, date and exposure, as shown
+}
+
+// readExif extracts EXIF data from a JPEG or TIFF file.
+func readExif(data []byte, format string) exifInfo {
+	var tiff []byte
+	switch format {
+	case "jpeg":
+		tiff = jpegExif(data)
+	case "tiff":
+		tiff = data
+	}
+	t, ok := newTIFF(tiff)
+	if !ok {
+		return exifInfo{}
+	}
+
+	ifd0 := t.ifd(t.uint32(4))
+	var info exifInfo
+	if o, ok := t.uint(ifd0[0x0112]); ok {
+		info.orientation = o
+	}
+	sub := map[uint16][]byte{}
+	if off, ok := t.uint(ifd0[0x8769]); ok {
+		sub = t.ifd(off)
+	}
+
+	maker, model := t.str(ifd0[0x010f]), t.str(ifd0[0x0110])
+	camera := model
+	if !strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		camera = strings.TrimSpace(maker + " " + model)
+	}
+	date := t.str(sub[0x9003])
+	if date == "" {
+		date = t.str(ifd0[0x0132])
+	}
+
+	var shot []string
+	if v, ok := t.rational(sub[0x829a]); ok && v > 0 {
+		if v < 1 {
+			shot = append(shot, fmt.Sprintf("1/%.0f s", 1/v))
+		} else {
+			shot = append(shot, fmt.Sprintf("%g s", v))
+		}
+	}
+	if v, ok := t.rational(sub[0x829d]); ok && v > 0 {
+		shot = append(shot, fmt.Sprintf("f/%g", math.Round(v*10)/10))
+	}
+	if v, ok := t.uint(sub[0x8827]); ok && v > 0 {
+		shot = append(shot, fmt.Sprintf("ISO %d", v))
+	}
+	if v, ok := t.rational(sub[0x920a]); ok && v > 0 {
+		shot = append(shot, fmt.Sprintf("%g mm", math.Round(v*10)/10))
+	}
+
+	for _, f := range []string{camera, t.str(sub[0xa434]), date, strings.Join(shot, " ")} {
+		if f != "" {
+			info.fields = append(info.fields, f)
+		}
+	}
+	return info
+}
+
+// jpegExif returns the TIFF structure in a JPEG's APP1 Exif segment.
+func jpegExif(data []byte) []byte {
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		if marker == 0xda || marker == 0xd9 { // start of scan, end of image
+			return nil
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 {
+			return nil
+		}
+		seg := data[i+4 : min(i+2+n, len(data))]
+		if marker == 0xe1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:]
+		}
+		i += 2 + n
+	}
+	return nil
+}
+
+// tiffData reads the tags of a TIFF structure, the format EXIF is stored in.
+type tiffData struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func newTIFF(data []byte) (tiffData, bool) {
+	if len(data) < 8 {
+		return tiffData{}, false
+	}
+	switch string(data[:4]) {
+	case "II*\x00":
+		return tiffData{data, binary.LittleEndian}, true
+	case "MM\x00*":
+		return tiffData{data, binary.BigEndian}, true
+	}
+	return tiffData{}, false
+}
+
+func (t tiffData) uint32(off int) int {
+	if off < 0 || off+4 > len(t.data) {
+		return 0
+	}
+	return int(t.order.Uint32(t.data[off:]))
+}
+
+// typeSizes are the sizes in bytes of TIFF field types 1 to 12.
+var typeSizes = [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+// ifd returns the fields of the directory at off by tag. Each field is its
+// type in two bytes followed by its value.
+func (t tiffData) ifd(off int) map[uint16][]byte {
+	fields := map[uint16][]byte{}
+	if off <= 0 || off+2 > len(t.data) {
+		return fields
+	}
+	n := int(t.order.Uint16(t.data[off:]))
+	for i := 0; i < n; i++ {
+		e := off + 2 + 12*i
+		if e+12 > len(t.data) {
+			break
+		}
+		typ := int(t.order.Uint16(t.data[e+2:]))
+		if typ <= 0 || typ >= len(typeSizes) {
+			continue
+		}
+		size := typeSizes[typ] * t.uint32(e+4)
+		start := e + 8
+		if size > 4 {
+			start = t.uint32(e + 8)
+		}
+		if size < 0 || start+size > len(t.data) {
+			continue
+		}
+		fields[t.order.Uint16(t.data[e:])] = append(t.data[e+2:e+4:e+4], t.data[start:start+size]...)
+	}
+	return fields
+}
+
+// str returns an ASCII field.
+func (t tiffData) str(f []byte) string {
+	if len(f) < 2 || t.order.Uint16(f) != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(f[2:]), "\x00"))
+}
+
+// uint returns the first value of a SHORT or LONG field.
+func (t tiffData) uint(f []byte) (int, bool) {
+	switch {
+	case len(f) >= 4 && t.order.Uint16(f) == 3:
+		return int(t.order.Uint16(f[2:])), true
+	case len(f) >= 6 && t.order.Uint16(f) == 4:
+		return int(t.order.Uint32(f[2:])), true
+	}
+	return 0, false
+}
+
+// rational returns the first value of a RATIONAL field.
+func (t tiffData) rational(f []byte) (float64, bool) {
+	if len(f) < 10 || t.order.Uint16(f) != 5 {
+		return 0, false
+	}
+	num, den := t.order.Uint32(f[2:]), t.order.Uint32(f[6:])
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
@@ -0,0 +1,166 @@
+package viewer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/feherkaroly/vc/internal/vfs"
+)
+
+const (
+	// localPollInterval is how often a local file is checked when it cannot
+	// be watched for changes.
+	localPollInterval = 500 * time.Millisecond
+
+	// remotePollInterval is how often a remote file's size is checked.
+	remotePollInterval = 2 * time.Second
+)
+
+// errWatchEnded is returned by watchLocal when the watched file was moved
+// away or deleted.
+var errWatchEnded = errors.New("file moved or deleted")
+
+// Following reports whether the viewer follows a growing file.
+func (v *Viewer) Following() bool {
+	return v.followCancel != nil
+}
+
+// toggleFollow starts or stops following the file like tail -f: new content
+// is shown as it is appended and the view stays at the end.
+func (v *Viewer) toggleFollow() {
+	if v.stopFollow() {
+		v.message = "Follow stopped"
+		return
+	}
+	if v.fsys == nil || v.queueUpdate == nil {
+		v.message = "Follow is not available here"
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	v.followCancel = cancel
+	v.message = "Following (f to stop)"
+	v.toEnd()
+
+	// A local file is known by its identity, so one rotated away and
+	// replaced is noticed even when the new file is larger.
+	fsys, path := v.fsys, v.filePath
+	var ident os.FileInfo
+	if f, ok := v.closer.(*os.File); ok {
+		ident, _ = f.Stat()
+	}
+	changed := func() {
+		fi, err := fsys.Stat(path)
+		if err != nil {
+			return
+		}
+		replaced := false
+		if ident != nil {
+			if cur, err := os.Stat(path); err == nil && !os.SameFile(ident, cur) {
+				ident, replaced = cur, true
+			}
+		}
+		v.queueUpdate(func() {
+			if ctx.Err() == nil {
+				v.resize(fi.Size, replaced)
+			}
+		})
+	}
+	go func() {
+		if fsys.IsLocal() {
+			// A file rotated away is watched again once its replacement
+			// appears.
+			err := watchLocal(ctx, path, changed)
+			for errors.Is(err, errWatchEnded) && waitForFile(ctx, path, changed) {
+				err = watchLocal(ctx, path, changed)
+			}
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+		}
+		interval := remotePollInterval
+		if fsys.IsLocal() {
+			interval = localPollInterval
+		}
+		poll(ctx, interval, changed)
+	}()
+}
+
+// stopFollow stops following the file.
+func (v *Viewer) stopFollow() bool {
+	if v.followCancel == nil {
+		return false
+	}
+	v.followCancel()
+	v.followCancel = nil
+	return true
+}
+
+// poll calls changed every interval until ctx is done.
+func poll(ctx context.Context, interval time.Duration, changed func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed()
+		}
+	}
+}
+
+// waitForFile polls path, calling changed, until a file is there again and
+// reports whether one appeared before ctx was done.
+func waitForFile(ctx context.Context, path string, changed func()) bool {
+	ticker := time.NewTicker(localPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			changed()
+			if _, err := os.Stat(path); err == nil {
+				return true
+			}
+		}
+	}
+}
+
+// resize updates the viewer for a file that now has size bytes. A file that
+// grew is read on from where it ended; one that shrank was truncated, and
+// one replaced by another was rotated, so it is opened again and its lines
+// are counted anew.
+func (v *Viewer) resize(size int64, replaced bool) {
+	if size == v.src.size && !replaced {
+		return
+	}
+	if v.syntax != nil {
+		v.syntax.spans = nil
+	}
+	if size > v.src.size && !replaced {
+		v.src.resize(size)
+		if v.index.grow(size) {
+			v.startIndex()
+		}
+	} else {
+		v.stopSearch()
+		v.index.stop()
+		if f, err := vfs.OpenRandom(v.fsys, v.filePath); err == nil {
+			if v.closer != nil {
+				v.closer.Close()
+			}
+			v.closer, v.src.r = f, f
+		}
+		v.src.resize(size)
+		v.index = newLineIndex(size)
+		v.startIndex()
+		v.match = nil
+	}
+	if v.Following() {
+		v.toEnd()
+	}
+}
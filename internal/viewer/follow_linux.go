@@ -0,0 +1,58 @@
+//go:build linux
+
+package viewer
+
+import (
+	"context"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchLocal calls changed whenever inotify reports that the file at path
+// was written to, until ctx is done. It returns an error if the file cannot
+// be watched or was moved away or deleted, so the caller can poll the path
+// for its replacement instead.
+func watchLocal(ctx context.Context, path string, changed func()) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	const mask = unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CLOSE_WRITE | unix.IN_MOVE_SELF | unix.IN_DELETE_SELF
+	if _, err := unix.InotifyAddWatch(fd, path, mask); err != nil {
+		return err
+	}
+	// Pick up anything written before the watch was added.
+	changed()
+
+	buf := make([]byte, 4096)
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for ctx.Err() == nil {
+		// Wake up regularly to notice that ctx is done.
+		n, err := unix.Poll(fds, 250)
+		if err == unix.EINTR || n == 0 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		n, err = unix.Read(fd, buf)
+		if err != nil {
+			continue
+		}
+		gone := false
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			if ev.Mask&(unix.IN_MOVE_SELF|unix.IN_DELETE_SELF|unix.IN_IGNORED) != 0 {
+				gone = true
+			}
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+		}
+		changed()
+		if gone {
+			return errWatchEnded
+		}
+	}
+	return nil
+}
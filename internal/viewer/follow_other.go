@@ -0,0 +1,14 @@
+//go:build !linux
+
+package viewer
+
+import (
+	"context"
+	"errors"
+)
+
+// watchLocal is only implemented with inotify; elsewhere local files are
+// polled.
+func watchLocal(ctx context.Context, path string, changed func()) error {
+	return errors.New("watching files is not supported on this platform")
+}
@@ -0,0 +1,71 @@
+package viewer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// askGoTo prompts for a line number in text mode or a byte offset in hex
+// mode. Either may also be given as a percentage of the file.
+func (v *Viewer) askGoTo() {
+	label := "Go to line (or N%):"
+	if v.hex {
+		label = "Go to offset (0x hex, decimal or N%):"
+	}
+	v.ask(label, "", func(text string) {
+		if err := v.goTo(strings.TrimSpace(text)); err != nil {
+			v.message = err.Error()
+		}
+	})
+}
+
+// goTo moves to the position described by text.
+func (v *Viewer) goTo(text string) error {
+	if text == "" {
+		return nil
+	}
+	var off int64
+	switch {
+	case strings.HasSuffix(text, "%"):
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(text, "%"), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return fmt.Errorf("invalid percentage %q", text)
+		}
+		off = int64(float64(v.src.size) * pct / 100)
+		if !v.hex {
+			off = v.src.lineStart(off)
+		}
+	case v.hex:
+		n, err := strconv.ParseInt(text, 0, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid offset %q", text)
+		}
+		off = n
+	default:
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid line number %q", text)
+		}
+		if v.streamed {
+			return fmt.Errorf("lines are not counted on this server; use N%%")
+		}
+		var ok bool
+		if off, ok = v.index.lineOffset(v.src, n); !ok {
+			return fmt.Errorf("line %d not counted yet", n)
+		}
+	}
+
+	if off >= v.src.size {
+		v.toEnd()
+		return nil
+	}
+	if v.hex {
+		off -= off % int64(v.hexWidth)
+	}
+	v.top, v.topRow = off, 0
+	if v.pastEnd() {
+		v.toEnd()
+	}
+	return nil
+}
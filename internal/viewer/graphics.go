@@ -0,0 +1,213 @@
+package viewer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// protocol is a way of showing images in the terminal.
+type protocol int
+
+const (
+	blocks protocol = iota // half block characters, works everywhere
+	kitty
+	iterm2
+	sixel
+)
+
+// detectProtocol guesses from the environment which graphics protocol the
+// terminal understands. VC_IMAGES set to kitty, iterm2, sixel or blocks
+// overrides the guess.
+func detectProtocol() protocol {
+	switch strings.ToLower(os.Getenv("VC_IMAGES")) {
+	case "kitty":
+		return kitty
+	case "iterm2":
+		return iterm2
+	case "sixel":
+		return sixel
+	case "blocks":
+		return blocks
+	}
+
+	term, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("TMUX") != "", strings.HasPrefix(term, "screen"):
+		// Multiplexers do not pass graphics through unless told to.
+		return blocks
+	case os.Getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty", program == "ghostty":
+		return kitty
+	case program == "iTerm.app", program == "WezTerm", os.Getenv("LC_TERMINAL") == "iTerm2":
+		return iterm2
+	case strings.Contains(term, "sixel"), term == "foot", strings.HasPrefix(term, "foot-"), term == "mlterm":
+		return sixel
+	}
+	return blocks
+}
+
+// cellSize returns the size of a character cell in pixels, or zeros when
+// the terminal does not tell.
+func cellSize(tty tcell.Tty, ok bool) (int, int) {
+	if !ok || tty == nil {
+		return 0, 0
+	}
+	ws, err := tty.WindowSize()
+	if err != nil {
+		return 0, 0
+	}
+	return ws.CellDimensions()
+}
+
+// DrawGraphics puts the picture on the terminal with its graphics protocol.
+// The image is written to the terminal directly, past tcell, so this must
+// run after the screen is drawn, from tview's after draw function. The
+// cells under the image are locked so tcell does not draw over it.
+func (v *Viewer) DrawGraphics(screen tcell.Screen) {
+	p := v.picture
+	if p == nil || p.want == p.placed {
+		return
+	}
+	tty, ok := screen.Tty()
+	if !ok || tty == nil {
+		return
+	}
+	if !p.placed.empty() {
+		p.clear()
+		screen.Show()
+	}
+	if p.want.empty() {
+		return
+	}
+
+	r := p.want
+	cw, ch := cellSize(tty, ok)
+	if key := [4]int{r.w, r.h, cw, ch}; p.payload == "" || p.paid != key {
+		p.payload, p.paid = p.encode(r, cw, ch), key
+	}
+	fmt.Fprintf(tty, "\x1b7\x1b[%d;%dH%s\x1b8", r.y+1, r.x+1, p.payload)
+	screen.LockRegion(r.x, r.y, r.w, r.h, true)
+	p.placed, p.screen = r, screen
+}
+
+// clear removes the picture from the terminal. The cells under it are
+// unlocked, so the next draw covers what is left of it.
+func (p *picture) clear() {
+	r := p.placed
+	if r.empty() || p.screen == nil {
+		return
+	}
+	p.screen.LockRegion(r.x, r.y, r.w, r.h, false)
+	if tty, ok := p.screen.Tty(); ok && tty != nil && p.proto == kitty {
+		io.WriteString(tty, "\x1b_Ga=d,d=A,q=2\x1b\\")
+	}
+	p.placed = rect{}
+}
+
+// encode returns the escape sequence that draws the picture in r. cw and
+// ch are the cell size in pixels, zero when unknown.
+func (p *picture) encode(r rect, cw, ch int) string {
+	known := cw > 0 && ch > 0
+	if !known {
+		cw, ch = 8, 16
+	}
+	w, h := p.fit(r.w*cw, r.h*ch)
+	if p.proto == sixel {
+		return encodeSixel(p.scale(w, h, true))
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, p.scale(w, h, false))
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if p.proto == iterm2 {
+		return fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=1:%s\a",
+			buf.Len(), r.w, r.h, data)
+	}
+
+	// Kitty takes the image in chunks of 4096 bytes. Unless the cell size
+	// is known it is stretched over the cells, so the size comes out right.
+	var sb strings.Builder
+	const chunk = 4096
+	for i := 0; i < len(data); i += chunk {
+		more := 0
+		if i+chunk < len(data) {
+			more = 1
+		}
+		part := data[i:min(i+chunk, len(data))]
+		switch {
+		case i > 0:
+			fmt.Fprintf(&sb, "\x1b_Gm=%d;%s\x1b\\", more, part)
+		case known:
+			fmt.Fprintf(&sb, "\x1b_Ga=T,f=100,q=2,C=1,m=%d;%s\x1b\\", more, part)
+		default:
+			fmt.Fprintf(&sb, "\x1b_Ga=T,f=100,q=2,C=1,c=%d,r=%d,m=%d;%s\x1b\\", r.w, r.h, more, part)
+		}
+	}
+	return sb.String()
+}
+
+// encodeSixel returns img as sixel graphics, with its colors reduced to a
+// 6×6×6 color cube.
+func encodeSixel(img *image.RGBA) string {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\x1bP0;1q\"1;1;%d;%d", w, h)
+	for i := 0; i < 216; i++ {
+		fmt.Fprintf(&sb, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+
+	level := func(v uint8) int { return (int(v)*5 + 127) / 255 }
+	bands := map[int][]byte{} // color to the sixels of one band
+	var used []int
+	for top := 0; top < h; top += 6 {
+		clear(bands)
+		used = used[:0]
+		for y := top; y < min(top+6, h); y++ {
+			for x := 0; x < w; x++ {
+				c := img.RGBAAt(x, y)
+				i := level(c.R)*36 + level(c.G)*6 + level(c.B)
+				six, ok := bands[i]
+				if !ok {
+					six = make([]byte, w)
+					bands[i] = six
+					used = append(used, i)
+				}
+				six[x] |= 1 << (y - top)
+			}
+		}
+		for n, i := range used {
+			if n > 0 {
+				sb.WriteByte('$')
+			}
+			fmt.Fprintf(&sb, "#%d", i)
+			writeSixels(&sb, bands[i])
+		}
+		sb.WriteByte('-')
+	}
+	sb.WriteString("\x1b\\")
+	return sb.String()
+}
+
+// writeSixels writes one color's row of sixels, run-length encoded.
+func writeSixels(sb *strings.Builder, six []byte) {
+	for x := 0; x < len(six); {
+		n := 1
+		for x+n < len(six) && six[x+n] == six[x] {
+			n++
+		}
+		c := 63 + six[x]
+		if n > 3 {
+			fmt.Fprintf(sb, "!%d%c", n, c)
+		} else {
+			sb.WriteString(strings.Repeat(string(rune(c)), n))
+		}
+		x += n
+	}
+}
@@ -0,0 +1,126 @@
+package viewer
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// HexWidths are the selectable numbers of bytes per hex dump line.
+var HexWidths = []int{8, 16, 24, 32}
+
+// DefaultHexWidth is the number of bytes per hex dump line unless set.
+const DefaultHexWidth = 16
+
+// looksBinary guesses whether data, the start of a file, is not text: it
+// contains a NUL byte or many control characters.
+func looksBinary(data []byte) bool {
+	ctrl := 0
+	for _, b := range data {
+		switch {
+		case b == 0:
+			return true
+		case b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\b' && b != 0x1b:
+			ctrl++
+		}
+	}
+	return ctrl*10 > len(data)
+}
+
+// offsetDigits returns the width of the hex offset column.
+func (v *Viewer) offsetDigits() int {
+	return max(8, len(fmt.Sprintf("%X", v.src.size)))
+}
+
+// drawHex draws one hex dump line for the bytes at off:
+//
+//	00000010  48 65 6c 6c 6f 2c 20 77  6f 72 6c 64 0a 00 01 02  Hello, world....
+//
+// Bytes whose offset is in hl are highlighted.
+func (v *Viewer) drawHex(screen tcell.Screen, off int64, x, y, width int, style tcell.Style, hl map[int64]bool) {
+	data := v.src.readAt(off, v.hexWidth)
+	offStyle := style.Foreground(tcell.ColorWhite)
+
+	col := 0
+	put := func(r rune, st tcell.Style) {
+		if col < width {
+			screen.SetContent(x+col, y, r, nil, st)
+		}
+		col++
+	}
+	for _, r := range fmt.Sprintf("%0*X", v.offsetDigits(), off) {
+		put(r, offStyle)
+	}
+	put(' ', style)
+	for i := 0; i < v.hexWidth; i++ {
+		put(' ', style)
+		if i > 0 && i%8 == 0 {
+			put(' ', style)
+		}
+		if i < len(data) {
+			st := style
+			if hl[off+int64(i)] {
+				st = matchStyle
+			}
+			h := fmt.Sprintf("%02x", data[i])
+			put(rune(h[0]), st)
+			put(rune(h[1]), st)
+		} else {
+			put(' ', style)
+			put(' ', style)
+		}
+	}
+	put(' ', style)
+	put(' ', style)
+	for i, b := range data {
+		st := style
+		if hl[off+int64(i)] {
+			st = matchStyle
+		}
+		if b < 0x20 || b > 0x7e {
+			b = '.'
+		}
+		put(rune(b), st)
+	}
+}
+
+// SetHexWidth sets the number of bytes per hex dump line.
+func (v *Viewer) SetHexWidth(n int) {
+	if n <= 0 {
+		n = DefaultHexWidth
+	}
+	v.hexWidth = n
+	v.top -= v.top % int64(n)
+}
+
+// HexWidth returns the number of bytes per hex dump line.
+func (v *Viewer) HexWidth() int {
+	return v.hexWidth
+}
+
+// hexLineWidth returns the number of columns a dump line of n bytes takes.
+func (v *Viewer) hexLineWidth(n int) int {
+	return v.offsetDigits() + 1 + 3*n + (n-1)/8 + 2 + n
+}
+
+// nextHexWidth switches to the next of HexWidths that fits on screen.
+func (v *Viewer) nextHexWidth() {
+	for _, w := range HexWidths {
+		if w > v.hexWidth && v.hexLineWidth(w) <= v.textWidth() {
+			v.SetHexWidth(w)
+			return
+		}
+	}
+	v.SetHexWidth(HexWidths[0])
+}
+
+// toggleHex switches between text and hex mode, keeping the position.
+func (v *Viewer) toggleHex() {
+	v.hex = !v.hex
+	if v.hex {
+		v.top -= v.top % int64(v.hexWidth)
+	} else {
+		v.top = v.src.lineStart(v.top)
+	}
+	v.topRow, v.left = 0, 0
+}
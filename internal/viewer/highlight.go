@@ -0,0 +1,129 @@
+package viewer
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/feherkaroly/vc/internal/textenc"
+	"github.com/feherkaroly/vc/internal/theme"
+)
+
+const (
+	// highlightBefore is how much text before the screen is lexed so that
+	// comments and strings opened above it are coloured correctly.
+	highlightBefore = 16 * 1024
+
+	// highlightAfter is how far past the screen a lexed region reaches, so
+	// scrolling down does not lex again for every line.
+	highlightAfter = 128 * 1024
+)
+
+// span colours the bytes [start, end) of the file.
+type span struct {
+	start, end int64
+	color      tcell.Color
+}
+
+// highlighter colours source code with a chroma lexer. Only a region around
+// the screen is lexed, so it works on files of any size.
+type highlighter struct {
+	lexer      chroma.Lexer
+	start, end int64
+	spans      []span
+}
+
+// lexerFor picks a lexer by file name, or by the shebang line of a script.
+func lexerFor(name string, head []byte) chroma.Lexer {
+	if l := lexers.Match(path.Base(strings.ReplaceAll(name, "\\", "/"))); l != nil {
+		return l
+	}
+	if strings.HasPrefix(string(head), "#!") {
+		return lexers.Analyse(string(head))
+	}
+	return nil
+}
+
+// colorAt returns the colour of the byte at off, if it has a special one.
+func (h *highlighter) colorAt(off int64) (tcell.Color, bool) {
+	i := sort.Search(len(h.spans), func(i int) bool { return h.spans[i].end > off })
+	if i < len(h.spans) && h.spans[i].start <= off {
+		return h.spans[i].color, true
+	}
+	return 0, false
+}
+
+// update lexes a new region unless [from, to) is already covered.
+func (h *highlighter) update(s *source, from, to int64) {
+	if h.spans != nil && from >= h.start && to <= h.end &&
+		(h.start == 0 || from-h.start >= highlightBefore/2) {
+		return
+	}
+
+	start := from
+	for start > 0 && from-start < highlightBefore {
+		start = s.prevLine(start)
+	}
+	end := min(s.size, to+highlightAfter)
+	if end < s.size {
+		_, end = s.line(s.lineStart(end))
+	}
+	h.start, h.end, h.spans = start, end, []span{}
+
+	// The lexer works on runes, so invalid UTF-8 is replaced byte for byte
+	// to keep token offsets equal to file offsets. Other encodings are
+	// decoded, and offsets mapped back through offs.
+	text, offs := s.enc.Decode(s.readAt(start, int(end-start)), start)
+	if offs == nil {
+		text = []byte(string(text))
+		for i := 0; i < len(text); {
+			r, n := utf8.DecodeRune(text[i:])
+			if r == utf8.RuneError && n == 1 {
+				text[i] = '?'
+			}
+			i += n
+		}
+	}
+
+	it, err := h.lexer.Tokenise(&chroma.TokeniseOptions{State: "root"}, string(text))
+	if err != nil {
+		return
+	}
+	pos := 0
+	for t := it(); t != chroma.EOF && pos < len(text); t = it() {
+		n := min(len(t.Value), len(text)-pos)
+		if c, ok := tokenColor(t.Type); ok && n > 0 {
+			lo := start + int64(textenc.Offset(offs, pos))
+			hi := start + int64(textenc.Offset(offs, pos+n))
+			h.spans = append(h.spans, span{lo, hi, c})
+		}
+		pos += n
+	}
+}
+
+// tokenColor maps a token type to a colour of the theme palette. Plain text
+// keeps the viewer's normal colour.
+func tokenColor(t chroma.TokenType) (tcell.Color, bool) {
+	switch {
+	case t.InCategory(chroma.Comment):
+		return theme.ColorSyntaxComment, true
+	case t.InSubCategory(chroma.LiteralNumber), t == chroma.KeywordConstant:
+		return theme.ColorSyntaxNumber, true
+	case t == chroma.KeywordType, t == chroma.NameBuiltin, t == chroma.NameClass:
+		return theme.ColorSyntaxType, true
+	case t.InCategory(chroma.Keyword), t == chroma.NameTag:
+		return theme.ColorSyntaxKeyword, true
+	case t == chroma.NameFunction, t == chroma.NameAttribute, t == chroma.NameDecorator:
+		return theme.ColorSyntaxFunction, true
+	case t.InSubCategory(chroma.LiteralString):
+		return theme.ColorSyntaxString, true
+	case t.InCategory(chroma.Generic):
+		return theme.ColorSyntaxMarkup, true
+	}
+	return 0, false
+}
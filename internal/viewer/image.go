@@ -0,0 +1,277 @@
+package viewer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register the formats image.Decode reads
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+
+	"github.com/feherkaroly/vc/internal/theme"
+)
+
+const (
+	// maxImageSize is the largest image file the viewer decodes.
+	maxImageSize = 128 << 20
+	// maxImagePixels limits the memory a decoded image takes.
+	maxImagePixels = 64 << 20
+)
+
+// picture is an image file shown as a picture rather than text. Without a
+// terminal graphics protocol it is drawn with half block characters, two
+// pixels to a cell.
+type picture struct {
+	format        string // as registered with the image package, e.g. "jpeg"
+	width, height int
+	exif          exifInfo
+
+	img     image.Image // decoded on first draw
+	err     error
+	loading bool
+
+	scaled    *image.RGBA // img scaled for the screen, kept between draws
+	scaledKey [3]int      // width, height and whether drawn over the background
+
+	proto   protocol
+	want    rect         // where the graphics belong after this draw
+	placed  rect         // where they are on the terminal
+	payload string       // escape sequence for want
+	paid    [4]int       // want's size and the cell size payload is for
+	screen  tcell.Screen // the graphics were drawn on, to clear them
+}
+
+// rect is an area of the screen in cells.
+type rect struct {
+	x, y, w, h int
+}
+
+func (r rect) empty() bool {
+	return r.w <= 0 || r.h <= 0
+}
+
+// newPicture returns a picture when r holds an image in a format the viewer
+// decodes, nil otherwise.
+func newPicture(r io.ReaderAt, size int64) *picture {
+	cfg, format, err := image.DecodeConfig(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil
+	}
+	return &picture{format: format, width: cfg.Width, height: cfg.Height, proto: detectProtocol()}
+}
+
+// describe returns the line shown above the picture: its format, size and
+// EXIF details.
+func (p *picture) describe(size int64) string {
+	parts := []string{fmt.Sprintf("%s  %d × %d  %s", strings.ToUpper(p.format), p.width, p.height, formatSize(size))}
+	parts = append(parts, p.exif.fields...)
+	return strings.Join(parts, "  ·  ")
+}
+
+// load decodes the whole image.
+func (p *picture) load(r io.ReaderAt, size int64) (image.Image, exifInfo, error) {
+	if size > maxImageSize || p.width*p.height > maxImagePixels {
+		return nil, exifInfo{}, errors.New("the image is too large to show")
+	}
+	data, err := io.ReadAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, exifInfo{}, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, exifInfo{}, err
+	}
+	return img, readExif(data, p.format), nil
+}
+
+// loadPicture decodes the image, in the background when the viewer can
+// update itself from another goroutine.
+func (v *Viewer) loadPicture() {
+	p := v.picture
+	p.loading = true
+	done := func(img image.Image, x exifInfo, err error) {
+		p.img, p.exif, p.err = img, x, err
+		p.loading = false
+	}
+	if v.queueUpdate == nil {
+		done(p.load(v.src.r, v.src.size))
+		return
+	}
+	go func() {
+		img, x, err := p.load(v.src.r, v.src.size)
+		v.queueUpdate(func() { done(img, x, err) })
+	}()
+}
+
+// upright returns the width and height of the image as displayed, that is
+// after turning it as its EXIF orientation says.
+func (p *picture) upright() (int, int) {
+	b := p.img.Bounds()
+	if p.exif.orientation >= 5 {
+		return b.Dy(), b.Dx()
+	}
+	return b.Dx(), b.Dy()
+}
+
+// fit returns the size of the image scaled down to fit in w×h pixels,
+// keeping its aspect ratio. Small images are not enlarged.
+func (p *picture) fit(w, h int) (int, int) {
+	iw, ih := p.upright()
+	s := min(float64(w)/float64(iw), float64(h)/float64(ih), 1)
+	return max(1, int(float64(iw)*s+0.5)), max(1, int(float64(ih)*s+0.5))
+}
+
+// scale returns the image scaled to w×h pixels and turned upright. With
+// over it is drawn over the panel background, so it has no transparency.
+func (p *picture) scale(w, h int, over bool) *image.RGBA {
+	key := [3]int{w, h, 0}
+	if over {
+		key[2] = 1
+	}
+	if p.scaled != nil && p.scaledKey == key {
+		return p.scaled
+	}
+
+	sw, sh := w, h
+	if p.exif.orientation >= 5 {
+		sw, sh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	op := draw.Src
+	if over {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(panelColor()), image.Point{}, draw.Src)
+		op = draw.Over
+	}
+	draw.BiLinear.Scale(dst, dst.Bounds(), p.img, p.img.Bounds(), op, nil)
+	p.scaled, p.scaledKey = orient(dst, p.exif.orientation), key
+	return p.scaled
+}
+
+// orient turns img as EXIF orientation o says.
+func orient(img *image.RGBA, o int) *image.RGBA {
+	if o < 2 || o > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // to be turned right
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // to be turned left
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, img.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
+
+// panelColor returns the panel background as a color images are drawn over.
+func panelColor() color.RGBA {
+	r, g, b := theme.ColorPanelBg.RGB()
+	if r < 0 {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{uint8(r), uint8(g), uint8(b), 0xff}
+}
+
+// drawPicture draws the description line and the picture below it.
+// Graphics protocols are drawn after the screen, by DrawGraphics; here
+// only their place is reserved.
+func (v *Viewer) drawPicture(screen tcell.Screen, x, y, width, height int) {
+	p := v.picture
+	tview.Print(screen, tview.Escape(p.describe(v.src.size)), x, y, width, tview.AlignLeft, theme.ColorHeaderFg)
+	y, height = y+2, height-2
+	if width < 1 || height < 1 {
+		return
+	}
+	if p.img == nil && p.err == nil && !p.loading {
+		v.loadPicture()
+	}
+	switch {
+	case p.err != nil:
+		tview.Print(screen, tview.Escape("Cannot show the image: "+p.err.Error()), x, y, width, tview.AlignLeft, theme.ColorNormalFile)
+		return
+	case p.img == nil:
+		tview.Print(screen, "Loading image...", x, y, width, tview.AlignLeft, theme.ColorNormalFile)
+		return
+	}
+
+	tty, ok := screen.Tty()
+	cw, ch := cellSize(tty, ok)
+	if p.proto == blocks || !ok || p.proto == sixel && cw == 0 {
+		p.drawBlocks(screen, x, y, width, height)
+		return
+	}
+	if cw == 0 {
+		// Kitty and iTerm2 scale the image to the cells it is given, so
+		// any size with the usual 1:2 cell shape will do.
+		cw, ch = 8, 16
+	}
+	w, h := p.fit(width*cw, height*ch)
+	gw, gh := (w+cw-1)/cw, (h+ch-1)/ch
+	p.want = rect{x + (width-gw)/2, y + (height-gh)/2, gw, gh}
+}
+
+// drawBlocks draws the picture with '▀' characters, the upper pixel in the
+// foreground color and the lower one in the background color.
+func (p *picture) drawBlocks(screen tcell.Screen, x, y, width, height int) {
+	w, h := p.fit(width, 2*height)
+	img := p.scale(w, h, true)
+	rows := (h + 1) / 2
+	x, y = x+(width-w)/2, y+(height-rows)/2
+	for row := 0; row < rows; row++ {
+		for col := 0; col < w; col++ {
+			style := tcell.StyleDefault.Foreground(rgb(img.RGBAAt(col, 2*row))).Background(theme.ColorPanelBg)
+			if 2*row+1 < h {
+				style = style.Background(rgb(img.RGBAAt(col, 2*row+1)))
+			}
+			screen.SetContent(x+col, y+row, '▀', nil, style)
+		}
+	}
+}
+
+func rgb(c color.RGBA) tcell.Color {
+	return tcell.NewRGBColor(int32(c.R), int32(c.G), int32(c.B))
+}
+
+// formatSize formats a file size for the description line.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,153 @@
+package viewer
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/feherkaroly/vc/internal/textenc"
+)
+
+// indexStride is the number of lines between two recorded line offsets.
+const indexStride = 1024
+
+// lineIndex counts the lines of a file in the background. It records the
+// offset of every indexStride-th line so the line number of any position
+// can be found by counting newlines from the nearest mark.
+type lineIndex struct {
+	mu      sync.Mutex
+	size    int64 // scanned up to; grows when a followed file does
+	scanned int64
+	lines   int
+	marks   []int64
+	done    bool
+	cancel  context.CancelFunc
+}
+
+func newLineIndex(size int64) *lineIndex {
+	return &lineIndex{size: size, marks: []int64{0}}
+}
+
+// run scans r for newlines in enc, continuing where an earlier scan
+// stopped, and calls notify as it makes progress. Chunks have an even size
+// so UTF-16 newlines are never split.
+func (x *lineIndex) run(ctx context.Context, r io.ReaderAt, enc *textenc.Encoding, notify func()) {
+	buf := make([]byte, 1<<20)
+	x.mu.Lock()
+	off, lines, marks := x.scanned, x.lines, x.marks
+	x.mu.Unlock()
+	last := time.Now()
+	for ctx.Err() == nil {
+		// The size is checked under the lock that grow takes, so a file
+		// that grows just as the scan ends is still scanned to its end.
+		x.mu.Lock()
+		size := x.size
+		if off >= size {
+			x.done = true
+			x.mu.Unlock()
+			break
+		}
+		x.mu.Unlock()
+
+		n, err := r.ReadAt(buf[:min(int64(len(buf)), size-off)], off)
+		for b := buf[:n]; ; {
+			pos := off + int64(n-len(b))
+			_, end := enc.IndexNewline(b, pos)
+			if end < 0 {
+				break
+			}
+			lines++
+			if lines%indexStride == 0 {
+				marks = append(marks, pos+int64(end))
+			}
+			b = b[end:]
+		}
+		off += int64(n)
+
+		x.mu.Lock()
+		x.scanned, x.lines, x.marks = off, lines, marks
+		x.mu.Unlock()
+
+		if n == 0 || (err != nil && err != io.EOF) {
+			break
+		}
+		if notify != nil && time.Since(last) > 250*time.Millisecond {
+			last = time.Now()
+			notify()
+		}
+	}
+	if notify != nil {
+		notify()
+	}
+}
+
+// grow extends the scan to a file that has grown to size. It reports
+// whether the scan had finished and so has to be run again.
+func (x *lineIndex) grow(size int64) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.size = size
+	if !x.done {
+		return false
+	}
+	x.done = false
+	return true
+}
+
+// stop cancels a running scan.
+func (x *lineIndex) stop() {
+	if x.cancel != nil {
+		x.cancel()
+	}
+}
+
+// total returns the number of lines in the file once the scan is complete.
+func (x *lineIndex) total(s *source) (int, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.done {
+		return 0, false
+	}
+	n := x.lines
+	if s.size > 0 {
+		unit := int64(s.enc.Unit())
+		tail := s.readAt(max(0, s.size-unit), int(unit))
+		if _, end := s.enc.LastNewline(tail, s.size-int64(len(tail))); end != len(tail) {
+			n++
+		}
+	}
+	return n, true
+}
+
+// lineAt returns the 1-based line number of the line containing off, if
+// the scan has reached it.
+func (x *lineIndex) lineAt(s *source, off int64) (int, bool) {
+	x.mu.Lock()
+	if off > x.scanned {
+		x.mu.Unlock()
+		return 0, false
+	}
+	marks := x.marks
+	x.mu.Unlock()
+
+	i := sort.Search(len(marks), func(i int) bool { return marks[i] > off }) - 1
+	return i*indexStride + s.countLines(marks[i], off) + 1, true
+}
+
+// lineOffset returns the offset of the 1-based line n, counting from the
+// nearest mark. It reports false while the scan has not reached the line:
+// counting up to it here would read the rest of the file on the caller's
+// goroutine.
+func (x *lineIndex) lineOffset(s *source, n int) (int64, bool) {
+	x.mu.Lock()
+	marks, lines, done := x.marks, x.lines, x.done
+	x.mu.Unlock()
+
+	if n-1 > lines {
+		return s.size, done
+	}
+	i := min((n-1)/indexStride, len(marks)-1)
+	return s.skipLines(marks[i], n-1-i*indexStride), true
+}
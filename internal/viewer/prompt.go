@@ -0,0 +1,67 @@
+package viewer
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/feherkaroly/vc/internal/theme"
+)
+
+// prompt is a one-line input shown on the bottom row of the viewer.
+type prompt struct {
+	label   string
+	text    []rune
+	onDone  func(text string)
+	keys    map[tcell.Key]func() // extra keys, e.g. to toggle options
+	relabel func() string        // recomputes label after an extra key
+}
+
+// ask opens a prompt; onDone is called with the text when Enter is pressed.
+func (v *Viewer) ask(label, text string, onDone func(text string)) {
+	v.prompt = &prompt{label: label, text: []rune(text), onDone: onDone}
+	v.message = ""
+}
+
+// promptKey handles a key while the prompt is open.
+func (v *Viewer) promptKey(event *tcell.EventKey) {
+	p := v.prompt
+	if f, ok := p.keys[event.Key()]; ok {
+		f()
+		if p.relabel != nil {
+			p.label = p.relabel()
+		}
+		return
+	}
+	switch event.Key() {
+	case tcell.KeyEscape:
+		v.prompt = nil
+	case tcell.KeyEnter:
+		v.prompt = nil
+		p.onDone(string(p.text))
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(p.text) > 0 {
+			p.text = p.text[:len(p.text)-1]
+		}
+	case tcell.KeyCtrlU:
+		p.text = nil
+	case tcell.KeyRune:
+		p.text = append(p.text, event.Rune())
+	}
+}
+
+// drawStatus draws the prompt, or the last message, on row y.
+func (v *Viewer) drawStatus(screen tcell.Screen, x, y, width int) {
+	style := tcell.StyleDefault.Background(theme.ColorDialogBg).Foreground(theme.ColorDialogFg)
+	for i := 0; i < width; i++ {
+		screen.SetContent(x+i, y, ' ', nil, style)
+	}
+	if v.prompt == nil {
+		tview.Print(screen, tview.Escape(v.message), x+1, y, width-1, tview.AlignLeft, theme.ColorDialogFg)
+		return
+	}
+	line := v.prompt.label + " " + string(v.prompt.text)
+	_, n := tview.Print(screen, tview.Escape(line), x+1, y, width-2, tview.AlignLeft, theme.ColorDialogFg)
+	if 1+n < width {
+		screen.ShowCursor(x+1+n, y)
+	}
+}
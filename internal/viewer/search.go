@@ -0,0 +1,343 @@
+package viewer
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/feherkaroly/vc/internal/textenc"
+)
+
+const (
+	searchChunk = 1 << 20
+
+	// searchOverlap is how much consecutive chunks overlap, and so the
+	// longest regex match that is found across a chunk boundary.
+	searchOverlap = maxLineLen
+)
+
+// searcher finds a pattern in the file. Text patterns are matched with a
+// regular expression, hex patterns as raw bytes.
+type searcher struct {
+	text string
+	re   *regexp.Regexp
+	raw  []byte
+	enc  *textenc.Encoding // text patterns match the decoded file
+}
+
+// newSearcher compiles a text pattern.
+func newSearcher(text string, regex, ignoreCase bool) (*searcher, error) {
+	expr := text
+	if !regex {
+		expr = regexp.QuoteMeta(text)
+	}
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile("(?m)" + expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	return &searcher{text: text, re: re}, nil
+}
+
+// newHexSearcher parses a byte pattern such as "de ad be ef", or a quoted
+// string searched for byte by byte.
+func newHexSearcher(text string) (*searcher, error) {
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		return &searcher{text: text, raw: []byte(text[1 : len(text)-1])}, nil
+	}
+	raw, err := hex.DecodeString(strings.Join(strings.Fields(text), ""))
+	if err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("invalid hex bytes %q", text)
+	}
+	return &searcher{text: text, raw: raw}, nil
+}
+
+// overlap returns how many bytes a match may extend into the next chunk.
+func (s *searcher) overlap() int {
+	if s.raw != nil {
+		return len(s.raw) - 1
+	}
+	return searchOverlap
+}
+
+// find returns the start and end of every non-empty match in b, which
+// starts at file offset base.
+func (s *searcher) find(b []byte, base int64) [][]int {
+	var out [][]int
+	if s.raw != nil {
+		for i := 0; ; {
+			j := bytes.Index(b[i:], s.raw)
+			if j < 0 {
+				return out
+			}
+			out = append(out, []int{i + j, i + j + len(s.raw)})
+			i += j + 1
+		}
+	}
+	text, offs := s.enc.Decode(b, base)
+	for _, m := range s.re.FindAllIndex(text, -1) {
+		if m[1] > m[0] {
+			out = append(out, []int{textenc.Offset(offs, m[0]), textenc.Offset(offs, m[1])})
+		}
+	}
+	return out
+}
+
+// scan looks for the first match starting at or after from, or with
+// backward the last match starting before from. It reads the file directly
+// rather than through the block cache so a long search does not evict the
+// visible blocks.
+func (s *searcher) scan(ctx context.Context, r io.ReaderAt, size, from int64, backward bool, progress func(pct int)) (int64, int64, bool, error) {
+	buf := make([]byte, searchChunk+s.overlap())
+	last := time.Now()
+	report := func(done int64) {
+		if progress != nil && time.Since(last) > 250*time.Millisecond {
+			last = time.Now()
+			progress(int(done * 100 / max(size, 1)))
+		}
+	}
+	read := func(start int64) ([]byte, error) {
+		n := min(int64(len(buf)), size-start)
+		got, err := r.ReadAt(buf[:n], start)
+		if err == io.EOF && int64(got) == n {
+			err = nil
+		}
+		return buf[:got], err
+	}
+
+	if !backward {
+		for pos := from; pos < size; pos += searchChunk {
+			if err := ctx.Err(); err != nil {
+				return 0, 0, false, err
+			}
+			b, err := read(pos)
+			if err != nil {
+				return 0, 0, false, err
+			}
+			if m := s.find(b, pos); len(m) > 0 {
+				return pos + int64(m[0][0]), int64(m[0][1] - m[0][0]), true, nil
+			}
+			report(pos - from)
+		}
+		return 0, 0, false, nil
+	}
+
+	for end := from; end > 0; {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, false, err
+		}
+		start := max(0, end-searchChunk)
+		b, err := read(start)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		matches := s.find(b, start)
+		for i := len(matches) - 1; i >= 0; i-- {
+			m := matches[i]
+			if start+int64(m[0]) < end {
+				return start + int64(m[0]), int64(m[1] - m[0]), true, nil
+			}
+		}
+		report(from - start)
+		end = start
+	}
+	return 0, 0, false, nil
+}
+
+// searchLabel returns the search prompt label showing the active options.
+func (v *Viewer) searchLabel(backward bool) string {
+	label := "Search"
+	if backward {
+		label += " backward"
+	}
+	if v.hex {
+		return label + " hex bytes (or \"text\"):"
+	}
+	var opts []string
+	if v.regex {
+		opts = append(opts, "regex")
+	}
+	if v.ignoreCase {
+		opts = append(opts, "ignore case")
+	}
+	if len(opts) > 0 {
+		label += " [" + strings.Join(opts, ", ") + "]"
+	}
+	return label + ":"
+}
+
+// askSearch prompts for a pattern. In text mode Ctrl+R toggles regular
+// expressions and Ctrl+T case sensitivity.
+func (v *Viewer) askSearch(backward bool) {
+	text := ""
+	if v.search != nil {
+		text = v.search.text
+	}
+	v.ask(v.searchLabel(backward), text, func(text string) {
+		if text == "" {
+			return
+		}
+		var s *searcher
+		var err error
+		if v.hex {
+			s, err = newHexSearcher(text)
+		} else {
+			s, err = newSearcher(text, v.regex, v.ignoreCase)
+		}
+		if s != nil {
+			s.enc = v.src.enc
+		}
+		if err != nil {
+			v.message = err.Error()
+			return
+		}
+		v.search, v.match, v.backward = s, nil, backward
+		v.findNext(false)
+	})
+	if !v.hex {
+		v.prompt.keys = map[tcell.Key]func(){
+			tcell.KeyCtrlR: func() { v.regex = !v.regex },
+			tcell.KeyCtrlT: func() { v.ignoreCase = !v.ignoreCase },
+		}
+		v.prompt.relabel = func() string { return v.searchLabel(backward) }
+	}
+}
+
+// findNext searches for the next match in the last search direction, or the
+// opposite one with reverse. A new search starts at the top of the screen.
+func (v *Viewer) findNext(reverse bool) {
+	if v.search == nil {
+		v.askSearch(reverse)
+		return
+	}
+	v.stopSearch()
+	backward := v.backward != reverse
+	from := v.top
+	if v.matchVisible() {
+		from = v.match.off
+		if !backward {
+			from++
+		}
+	}
+
+	s, r, size := v.search, v.src.r, v.src.size
+	found := func(off, n int64, ok bool, err error) {
+		v.searchCancel = nil
+		switch {
+		case err != nil:
+			v.message = "Search error: " + err.Error()
+		case !ok:
+			v.message = "Not found: " + s.text
+		default:
+			v.message = ""
+			v.showMatch(off, n)
+		}
+	}
+	if v.queueUpdate == nil {
+		found(s.scan(context.Background(), r, size, from, backward, nil))
+		return
+	}
+
+	// Results of a search that was stopped or replaced meanwhile are
+	// dropped: ctx is cancelled in both cases.
+	ctx, cancel := context.WithCancel(context.Background())
+	v.searchCancel = cancel
+	v.message = "Searching... (Esc to stop)"
+	go func() {
+		off, n, ok, err := s.scan(ctx, r, size, from, backward, func(pct int) {
+			v.queueUpdate(func() {
+				if ctx.Err() == nil {
+					v.message = fmt.Sprintf("Searching... %d%% (Esc to stop)", pct)
+				}
+			})
+		})
+		v.queueUpdate(func() {
+			if ctx.Err() == nil {
+				cancel()
+				found(off, n, ok, err)
+			}
+		})
+	}()
+}
+
+// stopSearch cancels a search running in the background.
+func (v *Viewer) stopSearch() bool {
+	if v.searchCancel == nil {
+		return false
+	}
+	v.searchCancel()
+	v.searchCancel = nil
+	v.message = ""
+	return true
+}
+
+// showMatch scrolls so the row with the match at off is at the top, or as
+// near as the end of the file allows.
+func (v *Viewer) showMatch(off, n int64) {
+	v.match = &match{off: off, n: n}
+	if v.hex {
+		v.top, v.topRow = off-off%int64(v.hexWidth), 0
+		if v.pastEnd() {
+			v.toEnd()
+		}
+		return
+	}
+
+	start := v.src.lineStart(off)
+	data, _ := v.src.line(start)
+	cs := toCells(data, v.src.enc, start)
+	i, col := 0, 0
+	for ; i < len(cs) && int64(cs[i].off) < off-start; i++ {
+		col += cs[i].w
+	}
+	v.top, v.topRow = start, 0
+	if v.wrap {
+		rows := wrapRows(cs, v.textWidth())
+		for ; v.topRow < len(rows)-1 && i >= len(rows[v.topRow]); v.topRow++ {
+			i -= len(rows[v.topRow])
+		}
+	} else if col < v.left || col >= v.left+v.textWidth() {
+		v.left = max(0, col-v.textWidth()/4)
+	}
+	if v.pastEnd() {
+		v.toEnd()
+	}
+}
+
+// matchVisible reports whether the last match is on screen, in which case
+// the next search continues from it rather than from the top of the screen.
+func (v *Viewer) matchVisible() bool {
+	return v.match != nil && v.match.off >= v.top && v.match.off < v.pageEnd()
+}
+
+// match is the position of the last match found.
+type match struct {
+	off, n int64
+}
+
+// hexMatches returns the offsets of the bytes matching the search on the
+// hex page starting at top.
+func (v *Viewer) hexMatches(top int64, rows int) map[int64]bool {
+	if v.search == nil {
+		return nil
+	}
+	ov := int64(v.search.overlap())
+	start := max(0, top-ov)
+	b := v.src.readAt(start, int(top-start)+rows*v.hexWidth+int(ov))
+	hl := make(map[int64]bool)
+	for _, m := range v.search.find(b, start) {
+		for i := m[0]; i < m[1]; i++ {
+			hl[start+int64(i)] = true
+		}
+	}
+	return hl
+}
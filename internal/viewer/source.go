@@ -0,0 +1,209 @@
+package viewer
+
+import (
+	"io"
+
+	"github.com/feherkaroly/vc/internal/textenc"
+)
+
+const (
+	blockSize = 64 * 1024
+	maxBlocks = 64
+
+	// maxLineLen is the longest line shown as one line; longer runs without
+	// a newline are split so a huge single-line file stays responsive.
+	maxLineLen = 4096
+
+	// maxScanBack is how far back a line start is searched for before
+	// giving up and splitting the line.
+	maxScanBack = 1 << 20
+)
+
+// source reads a file in blocks on demand and keeps the most recently used
+// blocks cached. It is only used from the UI goroutine.
+type source struct {
+	r      io.ReaderAt
+	size   int64
+	enc    *textenc.Encoding
+	blocks map[int64][]byte
+	order  []int64
+	err    error
+}
+
+func newSource(r io.ReaderAt, size int64) *source {
+	return &source{r: r, size: size, enc: textenc.UTF8, blocks: make(map[int64][]byte)}
+}
+
+// resize sets the size of a file that has changed on disk. Blocks that may
+// be stale are dropped: only the last one when the file grew, all of them
+// when it shrank.
+func (s *source) resize(size int64) {
+	if size < s.size {
+		s.blocks, s.order = make(map[int64][]byte), nil
+	} else {
+		last := s.size / blockSize
+		delete(s.blocks, last)
+		for i, b := range s.order {
+			if b == last {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+	s.size, s.err = size, nil
+}
+
+// block returns the cached block with index i, reading it if needed.
+func (s *source) block(i int64) []byte {
+	if b, ok := s.blocks[i]; ok {
+		return b
+	}
+	b := make([]byte, blockSize)
+	n, err := s.r.ReadAt(b, i*blockSize)
+	if err != nil && err != io.EOF && n == 0 {
+		s.err = err
+		return nil
+	}
+	b = b[:n]
+	if len(s.order) >= maxBlocks {
+		delete(s.blocks, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.blocks[i] = b
+	s.order = append(s.order, i)
+	return b
+}
+
+// readAt returns up to n bytes at off. The result may be shorter at the end
+// of the file or on a read error.
+func (s *source) readAt(off int64, n int) []byte {
+	if off+int64(n) > s.size {
+		n = int(s.size - off)
+	}
+	if n <= 0 {
+		return nil
+	}
+	first := off / blockSize
+	b := s.block(first)
+	start := int(off - first*blockSize)
+	if start+n <= len(b) {
+		return b[start:min(start+n, len(b))]
+	}
+	out := make([]byte, 0, n)
+	for i := first; len(out) < n; i++ {
+		b := s.block(i)
+		if i == first {
+			if start >= len(b) {
+				break
+			}
+			b = b[start:]
+		}
+		if len(b) == 0 {
+			break
+		}
+		out = append(out, b[:min(len(b), n-len(out))]...)
+	}
+	return out
+}
+
+// line returns the line starting at off without its line ending, and the
+// offset of the line after it.
+func (s *source) line(off int64) ([]byte, int64) {
+	data := s.readAt(off, maxLineLen+1)
+	if i, end := s.enc.IndexNewline(data, off); i >= 0 {
+		return data[:i], off + int64(end)
+	}
+	if len(data) > maxLineLen {
+		data = data[:maxLineLen]
+	}
+	if len(data) == 0 {
+		return nil, s.size
+	}
+	return data, off + int64(len(data))
+}
+
+// prevLine returns the start of the line before the one starting at off.
+func (s *source) prevLine(off int64) int64 {
+	if off <= 0 {
+		return 0
+	}
+	// The newline just before off ends the previous line, so the search
+	// starts before it.
+	start := int64(-1)
+	limit := max(0, off-maxScanBack)
+	for pos := off - int64(s.enc.Unit()) - 1; pos >= limit; {
+		bi := pos / blockSize
+		b := s.block(bi)
+		if b == nil {
+			return max(0, off-maxLineLen)
+		}
+		end := min(int(pos-bi*blockSize)+1, len(b))
+		lo := 0
+		if bi*blockSize < limit {
+			lo = int(limit - bi*blockSize)
+		}
+		if lo < end {
+			if _, e := s.enc.LastNewline(b[lo:end], bi*blockSize+int64(lo)); e >= 0 {
+				start = bi*blockSize + int64(lo+e)
+				break
+			}
+		}
+		pos = bi*blockSize - 1
+	}
+	if start < 0 {
+		if limit > 0 {
+			return max(0, off-maxLineLen)
+		}
+		start = 0
+	}
+	// Walk forward so long lines split the same way as when scrolling down.
+	for {
+		_, next := s.line(start)
+		if next >= off || next <= start {
+			return start
+		}
+		start = next
+	}
+}
+
+// lineStart returns the start of the line containing the byte at off.
+func (s *source) lineStart(off int64) int64 {
+	unit := int64(s.enc.Unit())
+	return s.prevLine(off - off%unit + unit)
+}
+
+// countLines returns the number of newlines in [from, to).
+func (s *source) countLines(from, to int64) int {
+	n := 0
+	for from < to {
+		b := s.readAt(from, int(min(to-from, blockSize)))
+		if len(b) == 0 {
+			break
+		}
+		n += s.enc.CountNewlines(b, from)
+		from += int64(len(b))
+	}
+	return n
+}
+
+// skipLines returns the offset after the n-th newline from off, or the
+// file size if there are fewer.
+func (s *source) skipLines(off int64, n int) int64 {
+	for n > 0 && off < s.size {
+		b := s.readAt(off, int(min(s.size-off, blockSize)))
+		if len(b) == 0 {
+			break
+		}
+		for n > 0 {
+			_, end := s.enc.IndexNewline(b, off)
+			if end < 0 {
+				off += int64(len(b))
+				break
+			}
+			off += int64(end)
+			b = b[end:]
+			n--
+		}
+	}
+	return min(off, s.size)
+}
@@ -1,152 +1,608 @@
 package viewer
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"os"
+	"io"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/rivo/uniseg"
 
+	"github.com/feherkaroly/vc/internal/textenc"
 	"github.com/feherkaroly/vc/internal/theme"
+	"github.com/feherkaroly/vc/internal/vfs"
 )
 
-// Viewer is a full-screen file viewer (F3).
+const tabWidth = 8
+
+// Viewer is a full-screen file viewer (F3). Files are read in blocks as they
+// are scrolled through, so opening a huge file is instant; the line count is
+// built in the background.
 type Viewer struct {
-	*tview.Frame
-	textView   *tview.TextView
-	doneFunc   func()
-	filePath   string
-	totalLines int
-}
-
-// New creates a new file viewer for the given path.
-func New(path string) *Viewer {
-	tv := tview.NewTextView().
-		SetDynamicColors(false).
-		SetScrollable(true).
-		SetWrap(true)
-
-	tv.SetBackgroundColor(theme.ColorPanelBg)
-	tv.SetTextColor(theme.ColorNormalFile)
-
-	frame := tview.NewFrame(tv).
-		SetBorders(0, 0, 0, 0, 0, 0)
-	frame.SetBorder(true)
-	frame.SetBorderColor(theme.ColorActiveBorder)
-	frame.SetBackgroundColor(theme.ColorPanelBg)
-	frame.SetTitle(" " + path + " ")
-	frame.SetTitleColor(theme.ColorHeaderFg)
-
-	v := &Viewer{
-		Frame:    frame,
-		textView: tv,
-		filePath: path,
-	}
-
-	data, err := os.ReadFile(path)
+	*tview.Box
+	src         *source
+	closer      io.Closer
+	index       *lineIndex
+	doneFunc    func()
+	queueUpdate func(func())
+	filePath    string
+	background  bool // index on first draw instead of up front
+	streamed    bool // read from a server without random access; not indexed
+	indexOnce   sync.Once
+	closeOnce   sync.Once
+
+	top    int64 // offset of the first line on screen
+	topRow int   // first wrapped row of that line on screen
+	left   int   // horizontal scroll in columns when not wrapping
+	wrap   bool
+	width  int
+	height int
+
+	hex      bool // hex dump instead of text
+	hexWidth int  // bytes per hex dump line
+
+	prompt  *prompt
+	message string // shown on the status row until the next key
+
+	search       *searcher
+	match        *match
+	backward     bool // direction of the last search
+	regex        bool
+	ignoreCase   bool
+	searchCancel func()
+
+	syntax *highlighter // nil when no lexer matches the file
+	plain  bool         // syntax highlighting switched off
+
+	picture *picture // set for image files, shown unless in hex mode
+
+	convertFunc func(enc *textenc.Encoding)
+
+	fsys         vfs.FileSystem // nil for text not read from a file
+	followCancel func()
+}
+
+// New creates a viewer for path on fsys. Errors opening the file are shown
+// in the viewer.
+func New(fsys vfs.FileSystem, path string) *Viewer {
+	fi, err := fsys.Stat(path)
 	if err != nil {
-		tv.SetText(fmt.Sprintf("Error reading file: %v", err))
-		v.totalLines = 1
-	} else {
-		content := string(data)
-		tv.SetText(content)
-		v.totalLines = strings.Count(content, "\n")
-		if len(content) > 0 && content[len(content)-1] != '\n' {
-			v.totalLines++
-		}
+		return NewFromText(path, fmt.Sprintf("Error reading file: %v", err))
+	}
+	f, err := vfs.OpenRandom(fsys, path)
+	if err != nil {
+		return NewFromText(path, fmt.Sprintf("Error reading file: %v", err))
 	}
 
-	tv.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyEscape, tcell.KeyF3, tcell.KeyF10:
-			if v.doneFunc != nil {
-				v.doneFunc()
-			}
-			return nil
-		case tcell.KeyRune:
-			if event.Rune() == 'q' || event.Rune() == 'Q' {
-				if v.doneFunc != nil {
-					v.doneFunc()
-				}
-				return nil
-			}
-		}
-		return event
-	})
-
+	v := newViewer(path, f, fi.Size)
+	v.fsys = fsys
+	v.closer = f
+	v.wrap = true
+	v.background = true
+	v.streamed = vfs.Streamed(f)
+	head := v.src.readAt(0, 8192)
+	enc := textenc.Detect(head)
+	v.hex = enc.Unit() == 1 && looksBinary(head)
+	if !v.hex {
+		v.src.enc = enc
+	} else if v.picture = newPicture(f, fi.Size); v.picture != nil {
+		v.hex = false
+	}
+	if l := lexerFor(path, head); l != nil {
+		v.syntax = &highlighter{lexer: l}
+	}
 	return v
 }
 
 // NewFromText creates a viewer that displays the given text content.
 func NewFromText(title string, content string) *Viewer {
-	tv := tview.NewTextView().
-		SetDynamicColors(false).
-		SetScrollable(true).
-		SetWrap(false)
-
-	tv.SetBackgroundColor(theme.ColorPanelBg)
-	tv.SetTextColor(theme.ColorNormalFile)
-	tv.SetText(content)
-
-	totalLines := strings.Count(content, "\n")
-	if len(content) > 0 && content[len(content)-1] != '\n' {
-		totalLines++
-	}
-
-	frame := tview.NewFrame(tv).
-		SetBorders(0, 0, 0, 0, 0, 0)
-	frame.SetBorder(true)
-	frame.SetBorderColor(theme.ColorActiveBorder)
-	frame.SetBackgroundColor(theme.ColorPanelBg)
-	frame.SetTitle(" " + title + " ")
-	frame.SetTitleColor(theme.ColorHeaderFg)
-
-	v := &Viewer{
-		Frame:      frame,
-		textView:   tv,
+	r := strings.NewReader(content)
+	v := newViewer(title, r, r.Size())
+	v.index.run(context.Background(), r, v.src.enc, nil)
+	return v
+}
+
+func newViewer(title string, r io.ReaderAt, size int64) *Viewer {
+	box := tview.NewBox()
+	box.SetBorder(true)
+	box.SetBorderColor(theme.ColorActiveBorder)
+	box.SetBackgroundColor(theme.ColorPanelBg)
+	box.SetTitleColor(theme.ColorHeaderFg)
+
+	return &Viewer{
+		Box:        box,
+		src:        newSource(r, size),
+		index:      newLineIndex(size),
 		filePath:   title,
-		totalLines: totalLines,
+		hexWidth:   DefaultHexWidth,
+		ignoreCase: true,
 	}
+}
+
+// SetDoneFunc sets the function called when the viewer is closed.
+func (v *Viewer) SetDoneFunc(f func()) {
+	v.doneFunc = f
+}
 
-	tv.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+// SetQueueUpdateFunc sets the function background work uses to update the
+// viewer and redraw it, normally tview's Application.QueueUpdateDraw.
+// Without it searches run in the foreground.
+func (v *Viewer) SetQueueUpdateFunc(f func(func())) {
+	v.queueUpdate = f
+}
+
+// Close stops the background indexing and following, and closes the file.
+func (v *Viewer) Close() {
+	v.closeOnce.Do(func() {
+		v.stopSearch()
+		v.stopFollow()
+		v.index.stop()
+		if v.picture != nil {
+			v.picture.clear()
+		}
+		if v.closer != nil {
+			v.closer.Close()
+		}
+	})
+}
+
+func (v *Viewer) done() {
+	v.Close()
+	if v.doneFunc != nil {
+		v.doneFunc()
+	}
+}
+
+// position returns the title's position indicator: the current and total
+// line while they are known, a percentage of the file otherwise. Pictures
+// have none.
+func (v *Viewer) position() string {
+	if v.showPicture() {
+		return ""
+	}
+	if v.hex {
+		pct := int64(100)
+		if v.src.size > 0 {
+			pct = min(v.top+int64(v.height*v.hexWidth), v.src.size) * 100 / v.src.size
+		}
+		return fmt.Sprintf("%0*X  %d%%", v.offsetDigits(), v.top, pct)
+	}
+	if !v.streamed {
+		cur, curOK := v.index.lineAt(v.src, v.top)
+		total, totalOK := v.index.total(v.src)
+		switch {
+		case curOK && totalOK:
+			return fmt.Sprintf("%d/%d", min(cur, max(total, 1)), total)
+		case curOK:
+			return fmt.Sprintf("%d/…", cur)
+		}
+	}
+	if v.src.size > 0 {
+		return fmt.Sprintf("%d%%", v.top*100/v.src.size)
+	}
+	return "0%"
+}
+
+// showPicture reports whether the file is shown as a picture.
+func (v *Viewer) showPicture() bool {
+	return v.picture != nil && !v.hex
+}
+
+// startIndex starts counting lines in the background. It is deferred to the
+// first draw so SetQueueUpdateFunc has been called by then. Streamed files
+// are not indexed: that would download the whole file, and its reads would
+// restart the download the screen is read from.
+func (v *Viewer) startIndex() {
+	v.index.stop()
+	if v.streamed {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	v.index.cancel = cancel
+	var notify func()
+	if v.queueUpdate != nil {
+		notify = func() { v.queueUpdate(func() {}) }
+	}
+	go v.index.run(ctx, v.src.r, v.src.enc, notify)
+}
+
+func (v *Viewer) Draw(screen tcell.Screen) {
+	if v.background {
+		v.indexOnce.Do(v.startIndex)
+	}
+	title := v.filePath
+	if v.src.enc != textenc.UTF8 {
+		title += "  [" + v.src.enc.Name + "]"
+	}
+	if v.Following() {
+		title += "  [follow]"
+	}
+	if pos := v.position(); pos != "" {
+		title += "  " + pos
+	}
+	v.SetTitle(" " + title + " ")
+	v.Box.DrawForSubclass(screen, v)
+
+	x, y, width, height := v.GetInnerRect()
+	if v.picture != nil {
+		v.picture.want = rect{}
+		if v.showPicture() {
+			// The status row is always kept free, so the picture does not
+			// move when a message comes and goes.
+			v.drawPicture(screen, x, y, width, height-1)
+		}
+	}
+	if v.prompt != nil || v.message != "" {
+		height--
+		v.drawStatus(screen, x, y+height, width)
+	}
+	v.width, v.height = width, height
+	style := tcell.StyleDefault.Background(theme.ColorPanelBg).Foreground(theme.ColorNormalFile)
+
+	if v.showPicture() {
+		return
+	}
+	if v.src.err != nil {
+		tview.Print(screen, "Error reading file: "+v.src.err.Error(), x, y, width, tview.AlignLeft, theme.ColorNormalFile)
+		return
+	}
+
+	if v.hex {
+		hl := v.hexMatches(v.top, height)
+		for line, off := 0, v.top; line < height && off < v.src.size; line++ {
+			v.drawHex(screen, off, x, y+line, width, style, hl)
+			off += int64(v.hexWidth)
+		}
+		return
+	}
+
+	syntax := v.syntax != nil && !v.plain
+	if syntax {
+		v.syntax.update(v.src, v.top, v.pageEnd())
+	}
+	off, row := v.top, v.topRow
+	for line := 0; line < height && off < v.src.size; {
+		data, next := v.src.line(off)
+		cs := toCells(data, v.src.enc, off)
+		var hl [][]int
+		if v.search != nil {
+			hl = v.search.find(data, off)
+		}
+		lineOff := off
+		styleAt := func(i int) tcell.Style {
+			if inRanges(hl, i) {
+				return matchStyle
+			}
+			if syntax {
+				if c, ok := v.syntax.colorAt(lineOff + int64(i)); ok {
+					return style.Foreground(c)
+				}
+			}
+			return style
+		}
+		if v.wrap {
+			rows := wrapRows(cs, width)
+			for ; row < len(rows) && line < height; row++ {
+				drawCells(screen, rows[row], x, y+line, width, 0, styleAt)
+				line++
+			}
+		} else {
+			drawCells(screen, cs, x, y+line, width, v.left, styleAt)
+			line++
+		}
+		off, row = next, 0
+	}
+}
+
+// InputHandler handles scrolling and closing the viewer.
+func (v *Viewer) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return v.WrapInputHandler(func(event *tcell.EventKey, _ func(p tview.Primitive)) {
+		if v.prompt != nil {
+			v.promptKey(event)
+			return
+		}
+		if event.Key() == tcell.KeyEscape && v.stopSearch() {
+			return
+		}
+		if v.searchCancel == nil {
+			v.message = ""
+		}
+		page := max(v.height-1, 1)
 		switch event.Key() {
 		case tcell.KeyEscape, tcell.KeyF3, tcell.KeyF10:
-			if v.doneFunc != nil {
-				v.doneFunc()
+			v.done()
+		case tcell.KeyF7:
+			if event.Modifiers()&tcell.ModShift != 0 {
+				v.findNext(false)
+			} else {
+				v.askSearch(false)
+			}
+		case tcell.KeyF19: // Shift+F7 on most terminals
+			v.findNext(false)
+		case tcell.KeyF4:
+			v.toggleHex()
+		case tcell.KeyF5:
+			v.askGoTo()
+		case tcell.KeyUp:
+			v.scrollUp(1)
+		case tcell.KeyDown, tcell.KeyEnter:
+			v.scrollDown(1)
+		case tcell.KeyPgUp:
+			v.scrollUp(page)
+		case tcell.KeyPgDn:
+			v.scrollDown(page)
+		case tcell.KeyHome:
+			v.top, v.topRow, v.left = 0, 0, 0
+		case tcell.KeyEnd:
+			v.toEnd()
+		case tcell.KeyLeft:
+			v.left = max(v.left-1, 0)
+		case tcell.KeyRight:
+			if !v.wrap && !v.hex {
+				v.left++
 			}
-			return nil
 		case tcell.KeyRune:
-			if event.Rune() == 'q' || event.Rune() == 'Q' {
-				if v.doneFunc != nil {
-					v.doneFunc()
+			switch event.Rune() {
+			case 'q', 'Q':
+				v.done()
+			case 'k':
+				v.scrollUp(1)
+			case 'j':
+				v.scrollDown(1)
+			case ' ':
+				v.scrollDown(page)
+			case 'b':
+				v.scrollUp(page)
+			case 'g':
+				v.top, v.topRow, v.left = 0, 0, 0
+			case 'G':
+				v.toEnd()
+			case 'h', 'H':
+				v.toggleHex()
+			case ':':
+				v.askGoTo()
+			case '/':
+				v.askSearch(false)
+			case '?':
+				v.askSearch(true)
+			case 's', 'S':
+				v.plain = !v.plain
+			case 'e':
+				v.setEncoding(v.src.enc.Step(false))
+			case 'E':
+				v.setEncoding(v.src.enc.Step(true))
+			case 'C':
+				v.convert()
+			case 'f', 'F':
+				v.toggleFollow()
+			case 'n':
+				v.findNext(false)
+			case 'N':
+				v.findNext(true)
+			case 'w', 'W':
+				if v.hex {
+					v.nextHexWidth()
+				} else {
+					v.wrap = !v.wrap
 				}
-				return nil
+				v.topRow, v.left = 0, 0
 			}
 		}
-		return event
 	})
+}
 
-	return v
+// MouseHandler scrolls the viewer with the mouse wheel.
+func (v *Viewer) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return v.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (bool, tview.Primitive) {
+		if !v.InRect(event.Position()) {
+			return false, nil
+		}
+		switch action {
+		case tview.MouseScrollUp:
+			v.scrollUp(3)
+		case tview.MouseScrollDown:
+			v.scrollDown(3)
+		case tview.MouseLeftClick:
+			setFocus(v)
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
 }
 
-func (v *Viewer) Draw(screen tcell.Screen) {
-	row, _ := v.textView.GetScrollOffset()
-	cur := row + 1
-	if cur < 1 {
-		cur = 1
+// rowsOf returns the number of screen rows the line at off takes.
+func (v *Viewer) rowsOf(off int64) int {
+	if !v.wrap || v.hex {
+		return 1
 	}
-	if cur > v.totalLines {
-		cur = v.totalLines
+	data, _ := v.src.line(off)
+	return len(wrapRows(toCells(data, v.src.enc, off), v.textWidth()))
+}
+
+func (v *Viewer) textWidth() int {
+	if v.width > 0 {
+		return v.width
 	}
-	v.Frame.SetTitle(fmt.Sprintf(" %s  %d/%d ", v.filePath, cur, v.totalLines))
-	v.Frame.Draw(screen)
+	return 80
 }
 
-func (v *Viewer) SetDoneFunc(f func()) {
-	v.doneFunc = f
+// next returns the screen row after the given one.
+func (v *Viewer) next(off int64, row int) (int64, int) {
+	if v.hex {
+		return min(off+int64(v.hexWidth), v.src.size), 0
+	}
+	if row+1 < v.rowsOf(off) {
+		return off, row + 1
+	}
+	_, n := v.src.line(off)
+	return n, 0
+}
+
+// prev returns the screen row before the given one.
+func (v *Viewer) prev(off int64, row int) (int64, int) {
+	if row > 0 {
+		return off, row - 1
+	}
+	if off == 0 {
+		return 0, 0
+	}
+	if v.hex {
+		return (off - 1) / int64(v.hexWidth) * int64(v.hexWidth), 0
+	}
+	p := v.src.prevLine(off)
+	return p, v.rowsOf(p) - 1
+}
+
+func (v *Viewer) scrollUp(n int) {
+	for i := 0; i < n && (v.top > 0 || v.topRow > 0); i++ {
+		v.top, v.topRow = v.prev(v.top, v.topRow)
+	}
+}
+
+// scrollDown moves n rows forward but never past the page that ends with
+// the last line of the file.
+func (v *Viewer) scrollDown(n int) {
+	for i := 0; i < n; i++ {
+		v.top, v.topRow = v.next(v.top, v.topRow)
+		if v.top >= v.src.size {
+			break
+		}
+	}
+	if v.pastEnd() {
+		v.toEnd()
+	}
+}
+
+// pageEnd returns the offset just past the last row on screen.
+func (v *Viewer) pageEnd() int64 {
+	off, row := v.top, v.topRow
+	for i := 0; i < max(v.height, 1) && off < v.src.size; i++ {
+		off, row = v.next(off, row)
+	}
+	return off
 }
 
-func (v *Viewer) Focus(delegate func(p tview.Primitive)) {
-	delegate(v.textView)
+// SetHighlight switches syntax highlighting of source files on or off.
+func (v *Viewer) SetHighlight(on bool) {
+	v.plain = !on
+}
+
+// Highlight reports whether syntax highlighting is on.
+func (v *Viewer) Highlight() bool {
+	return !v.plain
+}
+
+// pastEnd reports whether the page starting at top has rows left empty.
+func (v *Viewer) pastEnd() bool {
+	off, row := v.top, v.topRow
+	for i := 0; i < max(v.height, 1); i++ {
+		if off >= v.src.size {
+			return true
+		}
+		off, row = v.next(off, row)
+	}
+	return false
+}
+
+// toEnd shows the last page of the file. It works backwards from the end,
+// so it does not wait for the line index.
+func (v *Viewer) toEnd() {
+	off, row := v.src.size, 0
+	for i := 0; i < max(v.height, 1) && (off > 0 || row > 0); i++ {
+		off, row = v.prev(off, row)
+	}
+	v.top, v.topRow = off, row
+}
+
+// cell is one character on screen.
+type cell struct {
+	r   rune
+	w   int
+	off int // offset of the character's first byte in the line
+}
+
+// toCells decodes a line starting at file offset base for display. Tabs
+// are expanded, and control characters and invalid UTF-8 are shown as dots.
+func toCells(data []byte, enc *textenc.Encoding, base int64) []cell {
+	text, offs := enc.Decode(data, base)
+	text = bytes.TrimSuffix(text, []byte{'\r'})
+	cs := make([]cell, 0, len(text))
+	col := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		pos := textenc.Offset(offs, i)
+		i += size
+		switch {
+		case r == '\t':
+			for n := tabWidth - col%tabWidth; n > 0; n-- {
+				cs = append(cs, cell{' ', 1, pos})
+				col++
+			}
+			continue
+		case r == utf8.RuneError && size <= 1, r < 0x20, r == 0x7f:
+			r = '.'
+		}
+		w := uniseg.StringWidth(string(r))
+		if w == 0 {
+			continue
+		}
+		cs = append(cs, cell{r, w, pos})
+		col += w
+	}
+	return cs
+}
+
+// wrapRows splits cells into rows of at most width columns. An empty line
+// still takes one row.
+func wrapRows(cs []cell, width int) [][]cell {
+	if width < 2 {
+		width = 2
+	}
+	rows := [][]cell{}
+	start, col := 0, 0
+	for i, c := range cs {
+		if col+c.w > width {
+			rows = append(rows, cs[start:i])
+			start, col = i, 0
+		}
+		col += c.w
+	}
+	return append(rows, cs[start:])
+}
+
+// drawCells draws one row, skipping the first skip columns. styleAt gives
+// the style of the character at a byte offset in the line.
+func drawCells(screen tcell.Screen, cs []cell, x, y, width, skip int, styleAt func(off int) tcell.Style) {
+	col := 0
+	for _, c := range cs {
+		if col < skip {
+			col += c.w
+			continue
+		}
+		pos := col - skip
+		if pos+c.w > width {
+			break
+		}
+		screen.SetContent(x+pos, y, c.r, nil, styleAt(c.off))
+		col += c.w
+	}
+}
+
+// matchStyle highlights search matches.
+var matchStyle = tcell.StyleDefault.Background(theme.ColorCursorBg).Foreground(theme.ColorCursorFg)
+
+// inRanges reports whether off is inside one of the [start, end) ranges.
+func inRanges(ranges [][]int, off int) bool {
+	for _, r := range ranges {
+		if off >= r[0] && off < r[1] {
+			return true
+		}
+	}
+	return false
 }
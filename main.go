@@ -13,8 +13,13 @@ var Version = "3.2.1"
 
 func main() {
 	showVersion := flag.Bool("version", false, "Show version")
-	leftDir := flag.String("left", ".", "Left panel directory")
-	rightDir := flag.String("right", ".", "Right panel directory")
+	leftDir := flag.String("left", ".", "Left panel directory, server name or URL")
+	rightDir := flag.String("right", ".", "Right panel directory, server name or URL")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: vc [options] [left [right]]\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Panels accept a directory, a saved server name or a URL such as sftp://user@host/path.\n\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	if *showVersion {
@@ -22,47 +27,87 @@ func main() {
 		return
 	}
 
+	// Positional arguments fill the panels not set with -left/-right
+	args := flag.Args()
+	if len(args) > 0 && *leftDir == "." {
+		*leftDir = args[0]
+	}
+	if len(args) > 1 && *rightDir == "." {
+		*rightDir = args[1]
+	}
+
 	// Resolve CWD
 	wd, _ := os.Getwd()
 	if wd == "" {
 		wd = "."
 	}
 
+	cfg := config.Load()
+	left, right := location(cfg, *leftDir), location(cfg, *rightDir)
+
 	// Use saved paths if no explicit arguments given
 	if *leftDir == "." && *rightDir == "." {
-		cfg := config.Load()
 		// Active panel gets CWD, inactive panel gets saved path
 		if cfg.ActivePanel == 0 {
 			// Left is active → left=CWD, right=saved
-			*leftDir = wd
-			if cfg.RightPanel.Path != "" {
-				if info, err := os.Stat(cfg.RightPanel.Path); err == nil && info.IsDir() {
-					*rightDir = cfg.RightPanel.Path
-				}
+			left.Path = wd
+			if loc, ok := savedLocation(cfg, cfg.RightPanel); ok {
+				right = loc
 			}
 		} else {
 			// Right is active → right=CWD, left=saved
-			*rightDir = wd
-			if cfg.LeftPanel.Path != "" {
-				if info, err := os.Stat(cfg.LeftPanel.Path); err == nil && info.IsDir() {
-					*leftDir = cfg.LeftPanel.Path
-				}
+			right.Path = wd
+			if loc, ok := savedLocation(cfg, cfg.LeftPanel); ok {
+				left = loc
 			}
 		}
 	}
 
 	// Resolve any remaining relative paths
-	if *leftDir == "." {
-		*leftDir = wd
+	if left.Path == "." {
+		left.Path = wd
 	}
-	if *rightDir == "." {
-		*rightDir = wd
+	if right.Path == "." {
+		right.Path = wd
 	}
 
 	application.Version = Version
-	app := application.New(*leftDir, *rightDir)
+	app := application.New(left, right)
 	if err := app.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// savedLocation returns the saved location of a panel, skipping local
+// directories and saved servers that no longer exist.
+func savedLocation(cfg *config.Config, pc config.PanelConfig) (config.PanelConfig, bool) {
+	loc := config.PanelConfig{Path: pc.Path, Server: pc.Server}
+	switch {
+	case pc.Server == "":
+		if pc.Path == "" {
+			return loc, false
+		}
+		info, err := os.Stat(pc.Path)
+		return loc, err == nil && info.IsDir()
+	case config.IsServerURL(pc.Server):
+		return loc, true
+	}
+	_, ok := cfg.Server(pc.Server)
+	return loc, ok
+}
+
+// location tells a panel argument naming a saved server or a URL from a
+// directory. Existing local directories take precedence over server names.
+func location(cfg *config.Config, arg string) config.PanelConfig {
+	if config.IsServerURL(arg) {
+		return config.PanelConfig{Server: arg}
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return config.PanelConfig{Path: arg}
+	}
+	if _, ok := cfg.Server(arg); ok {
+		return config.PanelConfig{Server: arg}
+	}
+	return config.PanelConfig{Path: arg}
+}